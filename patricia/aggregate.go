@@ -0,0 +1,125 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+//------------------------------------------------------------------------------
+// Subtree aggregation
+//------------------------------------------------------------------------------
+
+// Summary is a value describing all the items stored in a subtree,
+// e.g. their sum or the maximum of their timestamps. nil stands for
+// an empty subtree.
+type Summary interface{}
+
+// Aggregator defines how subtree summaries are computed. Together with nil
+// as the identity, Combine must form a monoid, i.e. it must be associative.
+// Summaries are combined in the key order, so Combine does not
+// need to be commutative.
+//
+// Summaries are shared between cloned tries, so they must not be modified
+// in place once returned.
+type Aggregator interface {
+	// Summarize returns the summary of a single item.
+	// Returning nil makes the item count as missing.
+	Summarize(item Item) Summary
+	// Combine merges two non-nil summaries, a covering the keys preceding b.
+	Combine(a, b Summary) Summary
+}
+
+// Aggregation makes the trie keep a summary for every subtree, recomputed
+// along the path to the key modified whenever the trie changes.
+// The summaries can be retrieved using AggregateSubtree.
+func Aggregation(aggregator Aggregator) Option {
+	return func(trie *Trie) {
+		trie.aggregator = aggregator
+	}
+}
+
+// AggregateSubtree returns the summary of all the items with keys starting
+// with prefix, in O(len(prefix)) time. nil is returned when there are no such
+// items or when the trie was not created using Aggregation.
+func (trie *Trie) AggregateSubtree(prefix Prefix) Summary {
+	// Nil prefix not allowed.
+	if prefix == nil {
+		panic(ErrNilPrefix)
+	}
+
+	// Empty trie must be handled explicitly.
+	if trie.prefix == nil {
+		return nil
+	}
+
+	_, root, found, _ := trie.findSubtree(trie.encodeKey(prefix))
+	if !found {
+		return nil
+	}
+	return root.summary
+}
+
+// Internal helper methods -----------------------------------------------------
+
+// summarized returns whether the nodes keep anything describing their subtrees
+// that must be recomputed on every change.
+func (trie *Trie) summarized() bool {
+	return trie.aggregator != nil || trie.hash != nil
+}
+
+// aggregate recomputes the summary and the fingerprint of the node from its item
+// and the summaries and fingerprints of its children, which must be up to date.
+// key is the key the node represents, it is only used with KeyOrder.
+func (trie *Trie) aggregate(key Prefix) {
+	if trie.hash != nil {
+		trie.fingerprint = trie.children.fingerprint(fingerprintItem(trie.hash, trie.item))
+	}
+	if trie.aggregator == nil {
+		return
+	}
+
+	var summary Summary
+	if trie.item != nil {
+		summary = trie.aggregator.Summarize(trie.item)
+	}
+	trie.summary = trie.children.aggregate(trie.aggregator, summary, key, trie.keyOrder)
+}
+
+// aggregatePath recomputes the summaries of the nodes on the path to key,
+// bottom-up. The summaries of the nodes off the path must be up to date.
+func (trie *Trie) aggregatePath(key Prefix) {
+	if !trie.summarized() {
+		return
+	}
+	trie.aggregatePathFrom(key, 0)
+}
+
+// aggregatePathFrom implements aggregatePath for the node reached
+// after depth bytes of key.
+func (trie *Trie) aggregatePathFrom(key Prefix, depth int) {
+	rest := key[depth:]
+	common := trie.longestCommonPrefixLength(rest)
+	if common == len(trie.prefix) && common < len(rest) {
+		if child := trie.children.next(rest[common]); child != nil {
+			child.aggregatePathFrom(key, depth+common)
+		}
+	}
+
+	// The key the node represents is only needed to order the children.
+	var nodeKey Prefix
+	if trie.keyOrder != nil {
+		nodeKey = append(key[:depth:depth], trie.prefix...)
+	}
+	trie.aggregate(nodeKey)
+}
+
+// combineSummaries combines a and b, treating nil as the identity.
+func combineSummaries(aggregator Aggregator, a, b Summary) Summary {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	}
+	return aggregator.Combine(a, b)
+}
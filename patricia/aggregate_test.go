@@ -0,0 +1,229 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// Tests -----------------------------------------------------------------------
+
+func TestTrie_AggregateSubtree(t *testing.T) {
+	trie := NewTrie(Aggregation(sumAggregator{}))
+
+	data := []testData{
+		{"Pepa", 1, success},
+		{"Pepa Zdepa", 2, success},
+		{"Pepa Kuchar", 4, success},
+		{"Honza", 8, success},
+		{"Jenik", 16, success},
+	}
+
+	for _, v := range data {
+		t.Logf("INSERT prefix=%v, item=%v, success=%v", v.key, v.value, v.retVal)
+		if ok := trie.Insert([]byte(v.key), v.value); ok != v.retVal {
+			t.Fatalf("Unexpected return value, expected=%v, got=%v", v.retVal, ok)
+		}
+	}
+
+	cases := []struct {
+		prefix  string
+		summary Summary
+	}{
+		{"", 31},
+		{"Pepa", 7},
+		{"Pepa ", 6},
+		{"Pe", 7},
+		{"H", 8},
+		{"Honza", 8},
+		{"Honzik", nil},
+		{"X", nil},
+	}
+	for _, c := range cases {
+		if summary := trie.AggregateSubtree(Prefix(c.prefix)); summary != c.summary {
+			t.Errorf("Unexpected summary, prefix=%q, expected=%v, got=%v", c.prefix, c.summary, summary)
+		}
+	}
+
+	trie.Delete(Prefix("Pepa Zdepa"))
+	if summary := trie.AggregateSubtree(Prefix("Pepa")); summary != 5 {
+		t.Errorf("Unexpected summary after delete, expected=5, got=%v", summary)
+	}
+
+	trie.DeleteSubtree(Prefix("Pepa"))
+	if summary := trie.AggregateSubtree(Prefix{}); summary != 24 {
+		t.Errorf("Unexpected summary after subtree delete, expected=24, got=%v", summary)
+	}
+}
+
+func TestTrie_AggregateSubtreeOrder(t *testing.T) {
+	trie := trieOf(map[string]Item{"b": "b", "ab": "ab", "a": "a", "abc": "abc", "c": "c"},
+		Aggregation(concatAggregator{}))
+
+	if summary := trie.AggregateSubtree(Prefix{}); summary != "a,ab,abc,b,c" {
+		t.Errorf("Summaries not combined in alphabetical order: %v", summary)
+	}
+}
+
+func TestTrie_AggregateSubtreeNoAggregator(t *testing.T) {
+	trie := trieOf(map[string]Item{"Pepa": 1})
+	if summary := trie.AggregateSubtree(Prefix("Pepa")); summary != nil {
+		t.Errorf("Unexpected summary: %v", summary)
+	}
+}
+
+func TestTrie_AggregateSubtreeRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	randomKey := func() Prefix {
+		key := make(Prefix, rnd.Intn(12))
+		for j := range key {
+			key[j] = "abcd"[rnd.Intn(4)]
+		}
+		return key
+	}
+	randomItems := func(n int) map[string]Item {
+		items := make(map[string]Item)
+		for i := 0; i < n; i++ {
+			items[string(randomKey())] = rnd.Intn(100)
+		}
+		return items
+	}
+
+	optionSets := [][]Option{
+		nil,
+		{MaxPrefixPerNode(2), MaxChildrenPerSparseNode(2)},
+		{SlabAllocator(16)},
+	}
+	for _, options := range optionSets {
+		options = append(options, Aggregation(sumAggregator{}), Fingerprints(hashInt))
+		trie := NewTrie(options...)
+
+		for round := 0; round < 2000; round++ {
+			key := randomKey()
+			switch op := rnd.Intn(10); {
+			case op < 5:
+				trie.Set(key, rnd.Intn(100))
+			case op < 7:
+				trie.Delete(key)
+			case op < 8:
+				trie.Update(key, func(old Item, exists bool) (Item, bool) {
+					if exists {
+						return old.(int) + 1, old.(int) < 50
+					}
+					return 1, true
+				})
+			case op < 9:
+				trie.DeleteSubtree(key[:len(key)/3])
+			default:
+				trie.Filter(func(prefix Prefix, item Item) bool {
+					return item.(int)%7 != 0
+				})
+			}
+
+			if round%100 == 0 {
+				checkSummaries(t, trie)
+				checkFingerprints(t, trie)
+			}
+		}
+		checkSummaries(t, trie)
+		checkFingerprints(t, trie)
+
+		trie.Merge(trieOf(randomItems(100), options...), nil)
+		checkSummaries(t, trie)
+		checkFingerprints(t, trie)
+
+		trie.Difference(trieOf(randomItems(100), options...))
+		checkSummaries(t, trie)
+		checkFingerprints(t, trie)
+
+		checkSummaries(t, trie.Clone())
+		checkFingerprints(t, trie.Clone())
+		trie.Compact()
+		checkSummaries(t, trie)
+		checkFingerprints(t, trie)
+
+		trie.Release()
+		if summary := trie.AggregateSubtree(Prefix{}); summary != nil {
+			t.Errorf("Unexpected summary after release: %v", summary)
+		}
+
+		var pairs []KeyItem
+		for _, key := range []string{"a", "aaaaaaaaaaaa", "ab", "b", "bcdbcdbcdbcdbcdbcd", "c"} {
+			pairs = append(pairs, KeyItem{Prefix(key), len(key)})
+		}
+		if err := trie.BulkInsert(pairs); err != nil {
+			t.Fatal(err)
+		}
+		checkSummaries(t, trie)
+		checkFingerprints(t, trie)
+	}
+}
+
+// Helpers ---------------------------------------------------------------------
+
+type sumAggregator struct{}
+
+func (sumAggregator) Summarize(item Item) Summary {
+	return item
+}
+
+func (sumAggregator) Combine(a, b Summary) Summary {
+	return a.(int) + b.(int)
+}
+
+type concatAggregator struct{}
+
+func (concatAggregator) Summarize(item Item) Summary {
+	return item
+}
+
+func (concatAggregator) Combine(a, b Summary) Summary {
+	return a.(string) + "," + b.(string)
+}
+
+// checkSummaries compares the summaries of all the prefixes of the keys stored
+// against the sums computed by visiting the subtrees.
+func checkSummaries(t *testing.T, trie *Trie) {
+	t.Helper()
+
+	prefixes := map[string]bool{"": true}
+	for key := range itemsOf(trie) {
+		for i := range key {
+			prefixes[key[:i+1]] = true
+		}
+	}
+
+	for prefix := range prefixes {
+		var expected Summary
+		trie.VisitSubtree(Prefix(prefix), func(key Prefix, item Item) error {
+			if !strings.HasPrefix(string(key), prefix) {
+				t.Fatalf("Unexpected key visited: %q", key)
+			}
+			expected = combineSummaries(sumAggregator{}, expected, item)
+			return nil
+		})
+		if summary := trie.AggregateSubtree(Prefix(prefix)); summary != expected {
+			t.Fatalf("Unexpected summary, prefix=%q, expected=%v, got=%v", prefix, expected, summary)
+		}
+	}
+}
+
+// checkFingerprints recomputes the fingerprint of every node
+// from its item and its children.
+func checkFingerprints(t *testing.T, node *Trie) {
+	t.Helper()
+
+	expected := fingerprintItem(node.hash, node.item)
+	for _, child := range node.children.sorted() {
+		checkFingerprints(t, child)
+		expected += fingerprintChild(child)
+	}
+	if node.fingerprint != expected {
+		t.Fatalf("Unexpected fingerprint, prefix=%q, expected=%x, got=%x", node.prefix, expected, node.fingerprint)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+//------------------------------------------------------------------------------
+// Slab allocation
+//------------------------------------------------------------------------------
+
+const (
+	DefaultSlabSize = 4096
+
+	// Every node gets a few child pointers and prefix bytes on average,
+	// the pointer and byte slabs are scaled accordingly.
+	slabPointersPerNode = DefaultMaxChildrenPerSparseNode
+	slabBytesPerNode    = DefaultMaxPrefixPerNode
+)
+
+// arena carves nodes, child lists and prefix bytes out of large slabs.
+//
+// Memory is never returned to the arena, it is only reclaimed when the whole
+// arena becomes unreachable, see Trie.Compact and Trie.Release.
+type arena struct {
+	slabSize int
+	slabs    int
+
+	nodes  []Trie
+	sparse []sparseChildList
+	dense  []denseChildList
+	ptrs   []*Trie
+	bytes  []byte
+}
+
+func newArena(slabSize int) *arena {
+	if slabSize <= 0 {
+		slabSize = DefaultSlabSize
+	}
+	return &arena{slabSize: slabSize}
+}
+
+func (a *arena) node() *Trie {
+	if len(a.nodes) == 0 {
+		a.nodes = make([]Trie, a.slabSize)
+		a.slabs++
+	}
+	node := &a.nodes[0]
+	a.nodes = a.nodes[1:]
+	return node
+}
+
+func (a *arena) sparseChildList() *sparseChildList {
+	if len(a.sparse) == 0 {
+		a.sparse = make([]sparseChildList, a.slabSize)
+		a.slabs++
+	}
+	list := &a.sparse[0]
+	a.sparse = a.sparse[1:]
+	return list
+}
+
+func (a *arena) denseChildList() *denseChildList {
+	if len(a.dense) == 0 {
+		a.dense = make([]denseChildList, a.slabSize)
+		a.slabs++
+	}
+	list := &a.dense[0]
+	a.dense = a.dense[1:]
+	return list
+}
+
+// tries returns a zeroed slice of length and capacity n.
+func (a *arena) tries(n int) tries {
+	size := a.slabSize * slabPointersPerNode
+	if n > size {
+		return make(tries, n)
+	}
+	if n > len(a.ptrs) {
+		a.ptrs = make([]*Trie, size)
+		a.slabs++
+	}
+	t := a.ptrs[:n:n]
+	a.ptrs = a.ptrs[n:]
+	return t
+}
+
+// prefix returns a copy of the concatenation of the given prefixes.
+// The capacity of the copy is trimmed so that appending never spills
+// over into the neighbouring prefixes.
+func (a *arena) prefix(parts ...Prefix) Prefix {
+	n := 0
+	for _, part := range parts {
+		n += len(part)
+	}
+
+	// Nil marks an empty trie, the empty key must stay non-nil.
+	if n == 0 {
+		return Prefix{}
+	}
+
+	var p Prefix
+	if size := a.slabSize * slabBytesPerNode; n > size {
+		p = make(Prefix, n)
+	} else {
+		if n > len(a.bytes) {
+			a.bytes = make([]byte, size)
+			a.slabs++
+		}
+		p = a.bytes[:n:n]
+		a.bytes = a.bytes[n:]
+	}
+
+	i := 0
+	for _, part := range parts {
+		i += copy(p[i:], part)
+	}
+	return p
+}
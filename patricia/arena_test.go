@@ -0,0 +1,195 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+import (
+	"strconv"
+	"testing"
+)
+
+// Tests -----------------------------------------------------------------------
+
+func TestTrie_SlabAllocator(t *testing.T) {
+	trie := NewTrie(SlabAllocator(64))
+
+	const count = 5000
+	for i := 0; i < count; i++ {
+		key := strconv.Itoa(i * 7919)
+		if ok := trie.Insert(Prefix(key), i); !ok {
+			t.Fatalf("insert failed, prefix=%v", key)
+		}
+	}
+
+	for i := 0; i < count; i++ {
+		key := strconv.Itoa(i * 7919)
+		if item := trie.Get(Prefix(key)); item != i {
+			t.Errorf("Unexpected item, prefix=%v, expected=%v, got=%v", key, i, item)
+		}
+	}
+
+	if size := trie.size(); size != count {
+		t.Errorf("Unexpected trie size, expected=%v, got=%v", count, size)
+	}
+
+	// One slab per kind holds 64 entries, far less than one object per node.
+	if slabs := trie.arena.slabs; slabs == 0 || slabs > trie.total()/8 {
+		t.Errorf("Unexpected number of slabs allocated: %v for %v nodes", slabs, trie.total())
+	}
+}
+
+func TestTrie_SlabAllocatorCompact(t *testing.T) {
+	trie := NewTrie(SlabAllocator(64))
+
+	const count = 5000
+	for i := 0; i < count; i++ {
+		trie.Insert(Prefix(strconv.Itoa(i)), i)
+	}
+	for i := 0; i < count; i += 2 {
+		if ok := trie.Delete(Prefix(strconv.Itoa(i))); !ok {
+			t.Fatalf("delete failed, prefix=%v", i)
+		}
+	}
+
+	before := trie.arena.slabs
+	t.Log("COMPACT")
+	trie.Compact()
+	if after := trie.arena.slabs; after >= before {
+		t.Errorf("Compact did not reduce the number of slabs: before=%v, after=%v", before, after)
+	}
+
+	for i := 0; i < count; i++ {
+		item := trie.Get(Prefix(strconv.Itoa(i)))
+		switch {
+		case i%2 == 0 && item != nil:
+			t.Errorf("Unexpected item, prefix=%v, expected=<nil>, got=%v", i, item)
+		case i%2 == 1 && item != i:
+			t.Errorf("Unexpected item, prefix=%v, expected=%v, got=%v", i, i, item)
+		}
+	}
+
+	// The trie must remain usable after compaction.
+	if ok := trie.Insert(Prefix("0"), 0); !ok {
+		t.Error("insert after Compact failed")
+	}
+}
+
+func TestTrie_SlabAllocatorRelease(t *testing.T) {
+	trie := NewTrie(SlabAllocator(16))
+
+	for i := 0; i < 100; i++ {
+		trie.Insert(Prefix(strconv.Itoa(i)), i)
+	}
+
+	before := trie.arena.slabs
+	t.Log("RELEASE")
+	trie.Release()
+
+	if size := trie.size(); size != 0 {
+		t.Errorf("Unexpected trie size, expected=0, got=%v", size)
+	}
+	if after := trie.arena.slabs; after >= before {
+		t.Errorf("Release did not drop the slabs: before=%v, after=%v", before, after)
+	}
+
+	trie.Insert(Prefix("Pepa"), 1)
+	if item := trie.Get(Prefix("Pepa")); item != 1 {
+		t.Errorf("Unexpected item, expected=1, got=%v", item)
+	}
+}
+
+func TestTrie_SlabAllocatorClone(t *testing.T) {
+	trie := NewTrie(SlabAllocator(16))
+
+	data := []testData{
+		{"Pepa", 0, success},
+		{"Pepa Zdepa", 1, success},
+		{"Pepa Kuchar", 2, success},
+		{"Honza", 3, success},
+		{"Jenik", 4, success},
+	}
+
+	for _, v := range data {
+		t.Logf("INSERT prefix=%v, item=%v, success=%v", v.key, v.value, v.retVal)
+		if ok := trie.Insert(Prefix(v.key), v.value); ok != v.retVal {
+			t.Fatalf("Unexpected return value, expected=%v, got=%v", v.retVal, ok)
+		}
+	}
+
+	clone := trie.Clone()
+	trie.Release()
+
+	for _, v := range data {
+		if item := clone.Get(Prefix(v.key)); item != v.value {
+			t.Errorf("Unexpected item, prefix=%v, expected=%v, got=%v", v.key, v.value, item)
+		}
+	}
+}
+
+// Benchmarks ------------------------------------------------------------------
+
+func BenchmarkTrie_InsertHeap(b *testing.B) {
+	benchmarkInsert(b)
+}
+
+func BenchmarkTrie_InsertSlabs(b *testing.B) {
+	benchmarkInsert(b, SlabAllocator(DefaultSlabSize))
+}
+
+func benchmarkInsert(b *testing.B, options ...Option) {
+	keys := make([]Prefix, 10000)
+	for i := range keys {
+		keys[i] = Prefix(strconv.Itoa(i * 7919))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		trie := NewTrie(options...)
+		for _, key := range keys {
+			trie.Insert(key, true)
+		}
+	}
+}
+
+func TestTrie_SlabAllocatorEmptyKey(t *testing.T) {
+	trie := NewTrie(SlabAllocator(16))
+
+	trie.Set(Prefix(""), 1)
+	trie.Set(Prefix("a"), 2)
+	for key, item := range map[string]Item{"": 1, "a": 2} {
+		if got := trie.Get(Prefix(key)); got != item {
+			t.Errorf("Unexpected item, prefix=%q, expected=%v, got=%v", key, item, got)
+		}
+	}
+
+	// Compacting a trie with only the empty key left must keep it.
+	trie.Delete(Prefix("a"))
+	trie.Compact()
+	if trie.prefix == nil {
+		t.Fatal("Trie emptied by Compact")
+	}
+	if item := trie.Get(Prefix("")); item != 1 {
+		t.Errorf("Unexpected item, expected=1, got=%v", item)
+	}
+}
+
+func TestTrie_SlabAllocatorDeleteSubtreeReleasesItems(t *testing.T) {
+	trie := NewTrie(SlabAllocator(16))
+	trie.Insert(Prefix("Pepa"), 1)
+	trie.Insert(Prefix("Pepa Zdepa"), 2)
+	trie.Insert(Prefix("Honza"), 3)
+
+	_, node, _, _ := trie.findSubtree(Prefix("Pepa Zdepa"))
+	trie.DeleteSubtree(Prefix("Pepa"))
+
+	// The node stays in the slab, the item must not.
+	if node.item != nil {
+		t.Errorf("Item kept reachable by the unlinked node: %v", node.item)
+	}
+	if item := trie.Get(Prefix("Honza")); item != 3 {
+		t.Errorf("Unexpected item, expected=3, got=%v", item)
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+import "bytes"
+
+//------------------------------------------------------------------------------
+// Bulk loading
+//------------------------------------------------------------------------------
+
+// KeyItem is a key together with the item stored under it.
+type KeyItem struct {
+	Key  Prefix
+	Item Item
+}
+
+// BuildFromSorted creates a new trie containing the pairs returned by next,
+// which is called until it returns ok == false.
+//
+// The keys must come in strictly increasing bytewise order. The trie is then
+// constructed bottom-up in a single pass, which is much faster than inserting
+// the keys one by one. ErrUnsortedKeys or ErrDuplicateKey is returned
+// when the input is not ordered properly.
+func BuildFromSorted(next func() (key Prefix, item Item, ok bool), options ...Option) (*Trie, error) {
+	trie := NewTrie(options...)
+	b := newBuilder(trie)
+
+	for {
+		key, item, ok := next()
+		if !ok {
+			break
+		}
+		stored, original := trie.encodeOriginal(key)
+		if err := b.add(stored, original, item); err != nil {
+			return nil, err
+		}
+	}
+
+	b.close()
+	return trie, nil
+}
+
+// BulkInsert inserts pairs sorted by key in strictly increasing bytewise order.
+//
+// In case the trie is empty, it is constructed bottom-up as in BuildFromSorted.
+// Otherwise the pairs are inserted one by one, replacing existing items
+// as Set does. The trie is not modified when ErrUnsortedKeys
+// or ErrDuplicateKey is returned.
+func (trie *Trie) BulkInsert(pairs []KeyItem) error {
+	keys := make([]Prefix, len(pairs))
+	originals := make([]Prefix, len(pairs))
+	for i, pair := range pairs {
+		if pair.Key == nil {
+			panic(ErrNilPrefix)
+		}
+		keys[i], originals[i] = trie.encodeOriginal(pair.Key)
+		if i != 0 {
+			if err := checkOrder(keys[i-1], keys[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if trie.empty() {
+		b := newBuilder(trie)
+		for i, pair := range pairs {
+			b.add(keys[i], originals[i], pair.Item)
+		}
+		b.close()
+		return nil
+	}
+
+	for _, pair := range pairs {
+		trie.Set(pair.Key, pair.Item)
+	}
+	return nil
+}
+
+// builder -------------------------------------------------------------------
+
+// builder constructs a trie out of sorted keys. It keeps the path to the key
+// added last on a stack, nodes get finished once they are popped off it.
+type builder struct {
+	root    *Trie
+	prev    Prefix
+	started bool
+	stack   []buildFrame
+
+	// copyKeys forces keys to be copied even when the trie does not do so,
+	// which is necessary when the keys come from a reused buffer.
+	copyKeys bool
+}
+
+type buildFrame struct {
+	node *Trie
+	// depth is the length of the key represented by node.
+	depth    int
+	children tries
+}
+
+// newBuilder starts building into root, which must be empty.
+func newBuilder(root *Trie) *builder {
+	root.prefix = Prefix{}
+	return &builder{
+		root:  root,
+		stack: []buildFrame{{node: root}},
+	}
+}
+
+// add adds item under key, keeping original as its original key unless nil.
+func (b *builder) add(key, original Prefix, item Item) error {
+	// Nil prefix not allowed.
+	if key == nil {
+		panic(ErrNilPrefix)
+	}
+
+	var common int
+	if b.started {
+		if err := checkOrder(b.prev, key); err != nil {
+			return err
+		}
+		for ; common < len(b.prev) && common < len(key) && b.prev[common] == key[common]; common++ {
+		}
+	}
+
+	// Finish the nodes that are not on the path to key.
+	// b.prev still leads to them, it is only replaced afterwards.
+	for {
+		top := &b.stack[len(b.stack)-1]
+		if top.depth <= common {
+			break
+		}
+
+		parent := &b.stack[len(b.stack)-2]
+		if parent.depth < common {
+			// The path forks in the middle of the top node prefix, split it.
+			cut := common - parent.depth
+			node := b.root.newNode()
+			node.prefix = top.node.prefix[:cut:cut]
+			top.node.prefix = top.node.prefix[cut:]
+			*top = buildFrame{
+				node:     node,
+				depth:    common,
+				children: tries{b.finish(top)},
+			}
+			break
+		}
+
+		parent.children = append(parent.children, b.finish(top))
+		b.stack = b.stack[:len(b.stack)-1]
+	}
+	b.prev = append(b.prev[:0], key...)
+	b.started = true
+
+	top := &b.stack[len(b.stack)-1]
+	if len(key) == top.depth {
+		// Only the empty key can end up here, being the very first one.
+		top.node.item = item
+		top.node.original = b.root.storeOriginal(original)
+		return nil
+	}
+
+	leaf := b.root.newNode()
+	if b.copyKeys && b.root.arena == nil {
+		leaf.prefix = append(make(Prefix, 0, len(key)-top.depth), key[top.depth:]...)
+	} else {
+		leaf.prefix = b.root.storePrefix(key[top.depth:])
+	}
+	leaf.item = item
+	leaf.original = b.root.storeOriginal(original)
+	b.stack = append(b.stack, buildFrame{
+		node:  leaf,
+		depth: len(key),
+	})
+	return nil
+}
+
+// close finishes all the nodes still on the stack, including the root.
+func (b *builder) close() {
+	for len(b.stack) > 1 {
+		top := &b.stack[len(b.stack)-1]
+		parent := &b.stack[len(b.stack)-2]
+		parent.children = append(parent.children, b.finish(top))
+		b.stack = b.stack[:len(b.stack)-1]
+	}
+
+	if !b.started {
+		b.root.reset()
+		return
+	}
+	b.finish(&b.stack[0])
+}
+
+// finish sets the children of the node in the frame, choosing the child list
+// type up front. The node returned is the one to be linked to the parent,
+// which is not the same node in case the prefix is too long for a single node.
+func (b *builder) finish(frame *buildFrame) *Trie {
+	node := frame.node
+	if len(frame.children) > node.maxChildrenPerSparseNode {
+		node.children = newDenseChildListOf(node.arena, frame.children)
+	} else {
+		for _, child := range frame.children {
+			node.children = node.children.add(child)
+		}
+	}
+	node.aggregate(b.prev[:frame.depth])
+
+	// Split the prefix into a chain of nodes where necessary,
+	// the last node in the chain keeping the remainder as put does.
+	max := node.maxPrefixPerNode
+	if len(node.prefix) <= max || node == b.root {
+		return node
+	}
+
+	// The chain is built bottom-up so that every link can be aggregated
+	// from the one below.
+	prefix := node.prefix
+	cut := (len(prefix) - 1) / max * max
+	node.prefix = prefix[cut:]
+	for cut > 0 {
+		cut -= max
+		link := b.root.newNode()
+		link.prefix = prefix[cut : cut+max : cut+max]
+		link.children = link.children.add(node)
+		// A single child is ordered without knowing the key.
+		link.aggregate(nil)
+		node = link
+	}
+	return node
+}
+
+func checkOrder(prev, key Prefix) error {
+	switch bytes.Compare(prev, key) {
+	case 0:
+		return ErrDuplicateKey
+	case 1:
+		return ErrUnsortedKeys
+	}
+	return nil
+}
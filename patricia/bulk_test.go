@@ -0,0 +1,218 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// Tests -----------------------------------------------------------------------
+
+func TestTrie_BuildFromSorted(t *testing.T) {
+	keys := []string{
+		"",
+		"Honza",
+		"Jenik",
+		"Pepa",
+		"Pepa Kuchar",
+		"Pepa Zdepa",
+		"Pepa Zdepa a jeho neskutecne dlouhe jmeno",
+		"Pepan",
+	}
+
+	for _, options := range [][]Option{nil, {MaxPrefixPerNode(4)}, {SlabAllocator(8)}} {
+		trie, err := BuildFromSorted(sliceIterator(keys), options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, key := range keys {
+			if item := trie.Get(Prefix(key)); item != i {
+				t.Errorf("Unexpected item, prefix=%q, expected=%v, got=%v", key, i, item)
+			}
+		}
+		if visited := visitedKeys(trie); !reflect.DeepEqual(visited, keys) {
+			t.Errorf("Unexpected keys visited, expected=%q, got=%q", keys, visited)
+		}
+		checkMaxPrefixPerNode(t, trie)
+	}
+}
+
+func TestTrie_BuildFromSortedMatchesInsert(t *testing.T) {
+	keys := make([]string, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		keys = append(keys, strconv.Itoa(i*i))
+	}
+	sort.Strings(keys)
+
+	built, err := BuildFromSorted(sliceIterator(keys))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inserted := NewTrie()
+	for i, key := range keys {
+		inserted.Insert(Prefix(key), i)
+	}
+
+	if a, b := visitedKeys(built), visitedKeys(inserted); !reflect.DeepEqual(a, b) {
+		t.Errorf("Built and inserted tries differ")
+	}
+	checkMaxPrefixPerNode(t, built)
+
+	// The trie must remain fully functional.
+	for i, key := range keys {
+		if i%3 == 0 {
+			if ok := built.Delete(Prefix(key)); !ok {
+				t.Errorf("delete failed, prefix=%q", key)
+			}
+		}
+	}
+	if ok := built.Insert(Prefix("xxx"), -1); !ok {
+		t.Error("insert failed, prefix=xxx")
+	}
+	if size := built.size(); size != len(keys)-(len(keys)+2)/3+1 {
+		t.Errorf("Unexpected trie size: %v", size)
+	}
+}
+
+func TestTrie_BuildFromSortedDense(t *testing.T) {
+	var keys []string
+	for c := 'a'; c <= 'z'; c++ {
+		keys = append(keys, "ab"+string(c))
+	}
+
+	trie, err := BuildFromSorted(sliceIterator(keys))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, node, _, _ := trie.findSubtree(Prefix("ab"))
+	if _, ok := node.children.(*denseChildList); !ok {
+		t.Errorf("Unexpected child list type: %T", node.children)
+	}
+	if visited := visitedKeys(trie); !reflect.DeepEqual(visited, keys) {
+		t.Errorf("Unexpected keys visited, expected=%q, got=%q", keys, visited)
+	}
+}
+
+func TestTrie_BuildFromSortedInvalidInput(t *testing.T) {
+	data := []struct {
+		keys []string
+		err  error
+	}{
+		{[]string{"a", "c", "b"}, ErrUnsortedKeys},
+		{[]string{"ab", "a"}, ErrUnsortedKeys},
+		{[]string{"a", "ab", "ab"}, ErrDuplicateKey},
+	}
+
+	for _, v := range data {
+		if _, err := BuildFromSorted(sliceIterator(v.keys)); err != v.err {
+			t.Errorf("Unexpected error for %q, expected=%v, got=%v", v.keys, v.err, err)
+		}
+
+		trie := NewTrie()
+		if err := trie.BulkInsert(keyItems(v.keys)); err != v.err {
+			t.Errorf("Unexpected error for %q, expected=%v, got=%v", v.keys, v.err, err)
+		}
+		if !trie.empty() {
+			t.Errorf("Trie modified on invalid input %q", v.keys)
+		}
+	}
+}
+
+func TestTrie_BuildFromSortedEmpty(t *testing.T) {
+	trie, err := BuildFromSorted(sliceIterator(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trie.prefix != nil || !trie.empty() {
+		t.Errorf("Unexpected trie state:\n%s", trie.dump())
+	}
+}
+
+func TestTrie_BulkInsertNonEmpty(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(Prefix("Pepa"), "old")
+	trie.Insert(Prefix("Honza"), "old")
+
+	if err := trie.BulkInsert(keyItems([]string{"Jenik", "Pepa"})); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]Item{"Honza": "old", "Jenik": 0, "Pepa": 1}
+	for key, value := range expected {
+		if item := trie.Get(Prefix(key)); item != value {
+			t.Errorf("Unexpected item, prefix=%q, expected=%v, got=%v", key, value, item)
+		}
+	}
+}
+
+// Benchmarks ------------------------------------------------------------------
+
+func BenchmarkTrie_BulkInsert(b *testing.B) {
+	pairs := make([]KeyItem, 0, 10000)
+	for i := 0; i < 10000; i++ {
+		pairs = append(pairs, KeyItem{Prefix(strconv.Itoa(i * 7919)), true})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return string(pairs[i].Key) < string(pairs[j].Key)
+	})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewTrie().BulkInsert(pairs)
+	}
+}
+
+// Helpers ---------------------------------------------------------------------
+
+func sliceIterator(keys []string) func() (Prefix, Item, bool) {
+	i := 0
+	return func() (Prefix, Item, bool) {
+		if i == len(keys) {
+			return nil, nil, false
+		}
+		i++
+		return Prefix(keys[i-1]), i - 1, true
+	}
+}
+
+func keyItems(keys []string) []KeyItem {
+	pairs := make([]KeyItem, len(keys))
+	for i, key := range keys {
+		pairs[i] = KeyItem{Prefix(key), i}
+	}
+	return pairs
+}
+
+func visitedKeys(trie *Trie) []string {
+	var keys []string
+	trie.Visit(func(prefix Prefix, item Item) error {
+		keys = append(keys, string(prefix))
+		return nil
+	})
+	return keys
+}
+
+func checkMaxPrefixPerNode(t *testing.T, trie *Trie) {
+	var check func(node *Trie)
+	check = func(node *Trie) {
+		if len(node.prefix) > node.maxPrefixPerNode {
+			t.Errorf("Node prefix too long: %q", node.prefix)
+		}
+		for b := 0; b < 256; b++ {
+			if child := node.children.next(byte(b)); child != nil {
+				check(child)
+			}
+		}
+	}
+	check(trie)
+}
@@ -18,10 +18,14 @@ type childList interface {
 	remove(b byte)
 	replace(b byte, child *Trie)
 	next(b byte) *Trie
-	walk(prefix *Prefix, visitor VisitorFunc) error
+	first() *Trie
+	sorted() tries
+	walk(prefix *Prefix, visitor VisitorFunc, less LessFunc) error
 	print(w io.Writer, indent int)
-	clone() childList
+	clone(a *arena) childList
 	total() int
+	aggregate(aggregator Aggregator, summary Summary, key Prefix, less LessFunc) Summary
+	fingerprint(fingerprint uint64) uint64
 }
 
 type tries []*Trie
@@ -39,14 +43,62 @@ func (t tries) Swap(i, j int) {
 	t[i], t[j] = t[j], t[i]
 }
 
+// orderTries sorts the children of the node representing key using less,
+// see KeyOrder, bytewise when less is nil.
+func orderTries(t tries, key Prefix, less LessFunc) {
+	if less == nil || len(t) < 2 {
+		sort.Sort(t)
+		return
+	}
+
+	firstKeys := make([]Prefix, len(t))
+	for i, child := range t {
+		firstKeys[i] = child.firstKey(append(key[:len(key):len(key)], child.prefix...))
+	}
+	sort.Sort(&orderedTries{
+		tries:     t,
+		firstKeys: firstKeys,
+		less:      less,
+	})
+}
+
+// orderedTries sorts sibling nodes by the first keys stored in them.
+type orderedTries struct {
+	tries
+	firstKeys []Prefix
+	less      LessFunc
+}
+
+func (t *orderedTries) Less(i, j int) bool {
+	return lessKey(t.less, t.firstKeys[i], t.firstKeys[j])
+}
+
+func (t *orderedTries) Swap(i, j int) {
+	t.tries.Swap(i, j)
+	t.firstKeys[i], t.firstKeys[j] = t.firstKeys[j], t.firstKeys[i]
+}
+
 type sparseChildList struct {
 	children tries
 }
 
-func newSparseChildList(maxChildrenPerSparseNode int) childList {
-	return &sparseChildList{
-		children: make(tries, 0, maxChildrenPerSparseNode),
+func newSparseChildList(a *arena, maxChildrenPerSparseNode int) childList {
+	if a == nil {
+		return &sparseChildList{
+			children: make(tries, 0, maxChildrenPerSparseNode),
+		}
+	}
+
+	list := a.sparseChildList()
+	list.children = a.tries(maxChildrenPerSparseNode)[:0]
+	return list
+}
+
+func makeTries(a *arena, n int) tries {
+	if a != nil {
+		return a.tries(n)
 	}
+	return make(tries, n)
 }
 
 func (list *sparseChildList) length() int {
@@ -106,14 +158,31 @@ func (list *sparseChildList) next(b byte) *Trie {
 	return nil
 }
 
-func (list *sparseChildList) walk(prefix *Prefix, visitor VisitorFunc) error {
+// first returns the child with the prefix starting with the lowest byte.
+func (list *sparseChildList) first() *Trie {
+	first := list.children[0]
+	for _, child := range list.children[1:] {
+		if child.prefix[0] < first.prefix[0] {
+			first = child
+		}
+	}
+	return first
+}
 
+// sorted returns the children in the bytewise order.
+func (list *sparseChildList) sorted() tries {
 	sort.Sort(list.children)
+	return append(tries(nil), list.children...)
+}
+
+func (list *sparseChildList) walk(prefix *Prefix, visitor VisitorFunc, less LessFunc) error {
+
+	orderTries(list.children, *prefix, less)
 
 	for _, child := range list.children {
 		*prefix = append(*prefix, child.prefix...)
 		if child.item != nil {
-			err := visitor(*prefix, child.item)
+			err := visitor(child.visitKey(*prefix), child.item)
 			if err != nil {
 				if err == SkipSubtree {
 					*prefix = (*prefix)[:len(*prefix)-len(child.prefix)]
@@ -124,7 +193,7 @@ func (list *sparseChildList) walk(prefix *Prefix, visitor VisitorFunc) error {
 			}
 		}
 
-		err := child.children.walk(prefix, visitor)
+		err := child.children.walk(prefix, visitor, less)
 		*prefix = (*prefix)[:len(*prefix)-len(child.prefix)]
 		if err != nil {
 			return err
@@ -144,15 +213,39 @@ func (list *sparseChildList) total() int {
 	return tot
 }
 
-func (list *sparseChildList) clone() childList {
-	clones := make(tries, len(list.children), cap(list.children))
+// aggregate combines summary with the summaries of the children
+// of the node representing key, in order.
+func (list *sparseChildList) aggregate(aggregator Aggregator, summary Summary, key Prefix, less LessFunc) Summary {
+	orderTries(list.children, key, less)
+
+	for _, child := range list.children {
+		summary = combineSummaries(aggregator, summary, child.summary)
+	}
+	return summary
+}
+
+// fingerprint adds the fingerprints of the children to fingerprint.
+func (list *sparseChildList) fingerprint(fingerprint uint64) uint64 {
+	for _, child := range list.children {
+		fingerprint += fingerprintChild(child)
+	}
+	return fingerprint
+}
+
+func (list *sparseChildList) clone(a *arena) childList {
+	clones := makeTries(a, cap(list.children))[:len(list.children)]
 	for i, child := range list.children {
-		clones[i] = child.Clone()
+		clones[i] = child.clone(a)
 	}
 
-	return &sparseChildList{
-		children: clones,
+	if a == nil {
+		return &sparseChildList{
+			children: clones,
+		}
 	}
+	clone := a.sparseChildList()
+	clone.children = clones
+	return clone
 }
 
 func (list *sparseChildList) print(w io.Writer, indent int) {
@@ -194,19 +287,51 @@ func newDenseChildList(list *sparseChildList, child *Trie) childList {
 		max = b
 	}
 
-	children := make([]*Trie, max-min+1)
+	children := makeTries(child.arena, max-min+1)
 	for _, child := range list.children {
 		children[int(child.prefix[0])-min] = child
 	}
 	children[int(child.prefix[0])-min] = child
 
-	return &denseChildList{
+	return newDenseChildListFrom(child.arena, denseChildList{
 		min:         min,
 		max:         max,
 		numChildren: list.length() + 1,
 		headIndex:   0,
 		children:    children,
+	})
+}
+
+// newDenseChildListOf creates a dense list out of children sorted by prefix.
+func newDenseChildListOf(a *arena, children tries) childList {
+	var (
+		min int = int(children[0].prefix[0])
+		max int = int(children[len(children)-1].prefix[0])
+	)
+
+	dense := makeTries(a, max-min+1)
+	for _, child := range children {
+		dense[int(child.prefix[0])-min] = child
 	}
+
+	return newDenseChildListFrom(a, denseChildList{
+		min:         min,
+		max:         max,
+		numChildren: len(children),
+		headIndex:   0,
+		children:    dense,
+	})
+}
+
+func newDenseChildListFrom(a *arena, value denseChildList) *denseChildList {
+	var list *denseChildList
+	if a != nil {
+		list = a.denseChildList()
+	} else {
+		list = new(denseChildList)
+	}
+	*list = value
+	return list
 }
 
 func (list *denseChildList) length() int {
@@ -230,7 +355,7 @@ func (list *denseChildList) add(child *Trie) childList {
 		list.children[i] = child
 
 	case b < list.min:
-		children := make([]*Trie, list.max-b+1)
+		children := makeTries(child.arena, list.max-b+1)
 		i = 0
 		children[i] = child
 		copy(children[list.min-b:], list.children)
@@ -238,7 +363,7 @@ func (list *denseChildList) add(child *Trie) childList {
 		list.min = b
 
 	default: // b > list.max
-		children := make([]*Trie, b-list.min+1)
+		children := makeTries(child.arena, b-list.min+1)
 		i = b - list.min
 		children[i] = child
 		copy(children, list.children)
@@ -247,7 +372,7 @@ func (list *denseChildList) add(child *Trie) childList {
 	}
 
 	list.numChildren++
-	if i < list.headIndex {
+	if i < list.headIndex || list.numChildren == 1 {
 		list.headIndex = i
 	}
 	return list
@@ -291,14 +416,40 @@ func (list *denseChildList) next(b byte) *Trie {
 	return list.children[i-list.min]
 }
 
-func (list *denseChildList) walk(prefix *Prefix, visitor VisitorFunc) error {
+func (list *denseChildList) first() *Trie {
+	return list.head()
+}
+
+func (list *denseChildList) sorted() tries {
+	children := make(tries, 0, list.numChildren)
 	for _, child := range list.children {
+		if child != nil {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// ordered returns the children of the node representing key ordered
+// using less, possibly with nil gaps. Unless less is set, the list itself
+// is returned.
+func (list *denseChildList) ordered(key Prefix, less LessFunc) tries {
+	if less == nil {
+		return list.children
+	}
+	children := list.sorted()
+	orderTries(children, key, less)
+	return children
+}
+
+func (list *denseChildList) walk(prefix *Prefix, visitor VisitorFunc, less LessFunc) error {
+	for _, child := range list.ordered(*prefix, less) {
 		if child == nil {
 			continue
 		}
 		*prefix = append(*prefix, child.prefix...)
 		if child.item != nil {
-			if err := visitor(*prefix, child.item); err != nil {
+			if err := visitor(child.visitKey(*prefix), child.item); err != nil {
 				if err == SkipSubtree {
 					*prefix = (*prefix)[:len(*prefix)-len(child.prefix)]
 					continue
@@ -308,7 +459,7 @@ func (list *denseChildList) walk(prefix *Prefix, visitor VisitorFunc) error {
 			}
 		}
 
-		err := child.children.walk(prefix, visitor)
+		err := child.children.walk(prefix, visitor, less)
 		*prefix = (*prefix)[:len(*prefix)-len(child.prefix)]
 		if err != nil {
 			return err
@@ -326,15 +477,33 @@ func (list *denseChildList) print(w io.Writer, indent int) {
 	}
 }
 
-func (list *denseChildList) clone() childList {
-	clones := make(tries, cap(list.children))
+func (list *denseChildList) aggregate(aggregator Aggregator, summary Summary, key Prefix, less LessFunc) Summary {
+	for _, child := range list.ordered(key, less) {
+		if child != nil {
+			summary = combineSummaries(aggregator, summary, child.summary)
+		}
+	}
+	return summary
+}
+
+func (list *denseChildList) fingerprint(fingerprint uint64) uint64 {
+	for _, child := range list.children {
+		if child != nil {
+			fingerprint += fingerprintChild(child)
+		}
+	}
+	return fingerprint
+}
+
+func (list *denseChildList) clone(a *arena) childList {
+	clones := makeTries(a, cap(list.children))
 
 	if list.numChildren != 0 {
 		clonedCount := 0
 		for i := list.headIndex; i < len(list.children); i++ {
 			child := list.children[i]
 			if child != nil {
-				clones[i] = child.Clone()
+				clones[i] = child.clone(a)
 				clonedCount++
 				if clonedCount == list.numChildren {
 					break
@@ -343,13 +512,13 @@ func (list *denseChildList) clone() childList {
 		}
 	}
 
-	return &denseChildList{
+	return newDenseChildListFrom(a, denseChildList{
 		min:         list.min,
 		max:         list.max,
 		numChildren: list.numChildren,
 		headIndex:   list.headIndex,
 		children:    clones,
-	}
+	})
 }
 
 func (list *denseChildList) total() int {
@@ -0,0 +1,115 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+//------------------------------------------------------------------------------
+// Cursor
+//------------------------------------------------------------------------------
+
+// CursorState tells where a Cursor stands after consuming a byte.
+type CursorState int
+
+const (
+	// CursorDead means that no key starts with the bytes consumed.
+	// The cursor stays dead until reset.
+	CursorDead CursorState = iota
+	// CursorPrefix means that the bytes consumed are a proper prefix
+	// of some key, but they do not form a key themselves.
+	CursorPrefix
+	// CursorMatch means that the bytes consumed form a key. Longer keys
+	// may still follow, see Cursor.Extendable.
+	CursorMatch
+)
+
+func (state CursorState) String() string {
+	switch state {
+	case CursorDead:
+		return "dead"
+	case CursorPrefix:
+		return "prefix"
+	case CursorMatch:
+		return "match"
+	}
+	return "unknown"
+}
+
+// Cursor walks the trie one byte at a time, which makes it possible to match
+// keys against a stream without buffering the input.
+//
+// A cursor must not be used once the trie is modified, reset it instead.
+// The keys are matched as stored, so cursors make no sense for tries created
+// using ReverseKeys.
+type Cursor struct {
+	trie *Trie
+	// node is the node the cursor is in, offset being the number
+	// of bytes of its prefix consumed. nil node means a dead cursor.
+	node   *Trie
+	offset int
+}
+
+// Public API ------------------------------------------------------------------
+
+// Cursor returns a new cursor positioned at the root of the trie.
+func (trie *Trie) Cursor() *Cursor {
+	cursor := &Cursor{trie: trie}
+	cursor.Reset()
+	return cursor
+}
+
+// Reset moves the cursor back to the root of the trie.
+func (cursor *Cursor) Reset() {
+	cursor.node = cursor.trie
+	cursor.offset = 0
+
+	// Empty trie must be handled explicitly.
+	if cursor.trie.prefix == nil {
+		cursor.node = nil
+	}
+}
+
+// Advance consumes b, returning the state of the cursor afterwards and,
+// in case of CursorMatch, the item stored under the bytes consumed so far.
+func (cursor *Cursor) Advance(b byte) (state CursorState, item Item) {
+	if cursor.trie.caseInsensitive {
+		b = foldByte(b)
+	}
+
+	node := cursor.node
+	switch {
+	case node == nil:
+		return CursorDead, nil
+
+	case cursor.offset < len(node.prefix):
+		if node.prefix[cursor.offset] != b {
+			cursor.node = nil
+			return CursorDead, nil
+		}
+		cursor.offset++
+
+	default:
+		if node = node.children.next(b); node == nil {
+			cursor.node = nil
+			return CursorDead, nil
+		}
+		cursor.node = node
+		cursor.offset = 1
+	}
+
+	if cursor.offset == len(node.prefix) && node.item != nil {
+		return CursorMatch, node.item
+	}
+	return CursorPrefix, nil
+}
+
+// Extendable returns true when some key longer than the bytes consumed
+// starts with them, i.e. when advancing may lead to another match.
+func (cursor *Cursor) Extendable() bool {
+	node := cursor.node
+	if node == nil {
+		return false
+	}
+	return cursor.offset < len(node.prefix) || node.children.length() != 0
+}
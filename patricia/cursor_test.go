@@ -0,0 +1,92 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+import (
+	"bufio"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Tests -----------------------------------------------------------------------
+
+func TestCursor_Advance(t *testing.T) {
+	for _, options := range [][]Option{nil, {MaxPrefixPerNode(2)}} {
+		trie := trieOf(map[string]Item{"GET": 1, "GETEX": 2, "GETDEL": 3, "SET": 4}, options...)
+		cursor := trie.Cursor()
+
+		var states []string
+		for _, b := range []byte("GETEXX") {
+			state, item := cursor.Advance(b)
+			states = append(states, fmt.Sprintf("%v %v", state, item))
+		}
+		expected := []string{
+			"prefix <nil>",
+			"prefix <nil>",
+			"match 1",
+			"prefix <nil>",
+			"match 2",
+			"dead <nil>",
+		}
+		if !reflect.DeepEqual(states, expected) {
+			t.Errorf("Unexpected states, expected=%q, got=%q", expected, states)
+		}
+		if cursor.Extendable() {
+			t.Error("Dead cursor extendable")
+		}
+
+		cursor.Reset()
+		for _, b := range []byte("SET") {
+			cursor.Advance(b)
+		}
+		if cursor.Extendable() {
+			t.Error("Cursor extendable past the longest key")
+		}
+
+		cursor.Reset()
+		if state, _ := cursor.Advance('X'); state != CursorDead {
+			t.Errorf("Unexpected state, expected=%v, got=%v", CursorDead, state)
+		}
+		if state, _ := cursor.Advance('G'); state != CursorDead {
+			t.Error("Dead cursor revived")
+		}
+	}
+}
+
+func TestCursor_EmptyTrie(t *testing.T) {
+	cursor := NewTrie().Cursor()
+	if state, _ := cursor.Advance('a'); state != CursorDead {
+		t.Errorf("Unexpected state, expected=%v, got=%v", CursorDead, state)
+	}
+}
+
+// Dispatch commands read from a stream as soon as they are recognized.
+func TestCursor_Stream(t *testing.T) {
+	trie := trieOf(map[string]Item{"PING": "ping", "QUIT": "quit"})
+	cursor := trie.Cursor()
+
+	r := bufio.NewReader(strings.NewReader("PINGQUITPI"))
+	var commands []Item
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			break
+		}
+		switch state, item := cursor.Advance(b); state {
+		case CursorMatch:
+			commands = append(commands, item)
+			cursor.Reset()
+		case CursorDead:
+			t.Fatalf("Unexpected byte: %q", b)
+		}
+	}
+
+	if expected := []Item{"ping", "quit"}; !reflect.DeepEqual(commands, expected) {
+		t.Errorf("Unexpected commands, expected=%v, got=%v", expected, commands)
+	}
+}
@@ -0,0 +1,309 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+import "sort"
+
+//------------------------------------------------------------------------------
+// Diff
+//------------------------------------------------------------------------------
+
+// DiffKind tells how a key differs between two tries.
+type DiffKind int
+
+const (
+	// DiffAdded marks a key present only in the new trie.
+	DiffAdded DiffKind = iota
+	// DiffRemoved marks a key present only in the old trie.
+	DiffRemoved
+	// DiffChanged marks a key present in both tries with different items.
+	DiffChanged
+)
+
+func (kind DiffKind) String() string {
+	switch kind {
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	case DiffChanged:
+		return "changed"
+	}
+	return "unknown"
+}
+
+// Fingerprints makes the trie keep a fingerprint of every subtree, computed
+// from the keys and the hashes of the items in it, so that Diff can skip
+// the subtrees that are the same in both tries without descending them.
+// The fingerprints are recomputed along the path to the key modified
+// whenever the trie changes, the same way the summaries are, see Aggregation.
+//
+// hash must return the same value for items Diff is to treat as equal and it
+// should return different values for the items that are not. The fingerprints
+// are 64 bits wide, so a collision can hide a difference, although that is
+// very unlikely unless hash itself collides.
+func Fingerprints(hash func(item Item) uint64) Option {
+	return func(trie *Trie) {
+		trie.hash = hash
+	}
+}
+
+// DiffFunc is called for every key that differs, old or new being nil
+// when the key is missing in the respective trie.
+//
+// key is only valid until the function returns.
+type DiffFunc func(key Prefix, kind DiffKind, old, new Item) error
+
+// Diff walks tries a and b in lockstep and calls visitor for every key that was
+// added, removed or changed when going from a to b, in key order.
+// Both tries must use the same KeyOrder.
+//
+// Items are compared using eq, which can be nil, in which case the items are
+// compared using ==. Tries never share nodes, not even after Clone, so every key
+// present in either trie is visited, unless both tries were created using
+// Fingerprints with the same hash function. Subtrees rooted at the same key
+// having the same fingerprint are skipped then, so comparing a trie with
+// a slightly modified clone only descends the paths to the keys modified.
+//
+// If an error is returned from visitor, Diff stops and returns that error.
+func Diff(a, b *Trie, eq func(old, new Item) bool, visitor DiffFunc) error {
+	if eq == nil {
+		eq = func(old, new Item) bool {
+			return old == new
+		}
+	}
+
+	if a.reverseKeys {
+		var buf Prefix
+		decoded := visitor
+		visitor = func(key Prefix, kind DiffKind, old, new Item) error {
+			buf = a.decodeKey(buf, key)
+			return decoded(buf, kind, old, new)
+		}
+	}
+
+	d := &differ{
+		eq:           eq,
+		visitor:      visitor,
+		less:         a.keyOrder,
+		fingerprints: a.hash != nil && b.hash != nil,
+	}
+
+	switch {
+	case a.prefix == nil && b.prefix == nil:
+		return nil
+	case a.prefix == nil:
+		return d.only(b, 0, DiffAdded)
+	case b.prefix == nil:
+		return d.only(a, 0, DiffRemoved)
+	}
+	return d.diff(a, 0, b, 0)
+}
+
+// differ descends two tries in lockstep. A position in a trie is described
+// by a node and the number of bytes of its prefix already matched.
+type differ struct {
+	eq           func(old, new Item) bool
+	visitor      DiffFunc
+	less         LessFunc
+	fingerprints bool
+	key          Prefix
+}
+
+func (d *differ) diff(a *Trie, ia int, b *Trie, ib int) error {
+	// Nothing can differ when a trie is compared with itself.
+	if a == b && ia == ib {
+		return nil
+	}
+
+	// The fingerprints only describe the same keys when the nodes end
+	// at the same key.
+	if d.fingerprints && ia == 0 && ib == 0 && a.fingerprint == b.fingerprint &&
+		string(a.prefix) == string(b.prefix) {
+		return nil
+	}
+
+	ra, rb := a.prefix[ia:], b.prefix[ib:]
+	common := 0
+	for ; common < len(ra) && common < len(rb) && ra[common] == rb[common]; common++ {
+	}
+
+	base := len(d.key)
+	d.key = append(d.key, ra[:common]...)
+	defer func() {
+		d.key = d.key[:base]
+	}()
+
+	// Collect the subtrees of both tries continuing from d.key.
+	var as, bs []diffSide
+	switch {
+	// The tries fork in the middle of both prefixes.
+	case common < len(ra) && common < len(rb):
+		as = []diffSide{{a, ia + common}}
+		bs = []diffSide{{b, ib + common}}
+
+	// a continues in the middle of its prefix.
+	case common < len(ra):
+		if b.item != nil {
+			if err := d.visitor(b.visitKey(d.key), DiffAdded, nil, b.item); err != nil {
+				return err
+			}
+		}
+		as = []diffSide{{a, ia + common}}
+		bs = sidesOf(b.children.sorted())
+
+	// b continues in the middle of its prefix.
+	case common < len(rb):
+		if a.item != nil {
+			if err := d.visitor(a.visitKey(d.key), DiffRemoved, a.item, nil); err != nil {
+				return err
+			}
+		}
+		as = sidesOf(a.children.sorted())
+		bs = []diffSide{{b, ib + common}}
+
+	// Both a and b end at the same key.
+	default:
+		var err error
+		switch {
+		case a.item != nil && b.item != nil:
+			if !d.eq(a.item, b.item) {
+				err = d.visitor(b.visitKey(d.key), DiffChanged, a.item, b.item)
+			}
+		case a.item != nil:
+			err = d.visitor(a.visitKey(d.key), DiffRemoved, a.item, nil)
+		case b.item != nil:
+			err = d.visitor(b.visitKey(d.key), DiffAdded, nil, b.item)
+		}
+		if err != nil {
+			return err
+		}
+		as = sidesOf(a.children.sorted())
+		bs = sidesOf(b.children.sorted())
+	}
+
+	for _, fork := range d.pair(as, bs) {
+		var err error
+		switch {
+		case fork.b.node == nil:
+			err = d.only(fork.a.node, fork.a.offset, DiffRemoved)
+		case fork.a.node == nil:
+			err = d.only(fork.b.node, fork.b.offset, DiffAdded)
+		default:
+			err = d.diff(fork.a.node, fork.a.offset, fork.b.node, fork.b.offset)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffSide is a position in one of the tries, see differ.
+type diffSide struct {
+	node   *Trie
+	offset int
+}
+
+func sidesOf(children tries) []diffSide {
+	sides := make([]diffSide, len(children))
+	for i, child := range children {
+		sides[i] = diffSide{child, 0}
+	}
+	return sides
+}
+
+func (side diffSide) first() byte {
+	return side.node.prefix[side.offset]
+}
+
+// diffFork pairs the subtrees of both tries continuing from the same key
+// with the same byte. Either of them can be missing, its node being nil.
+type diffFork struct {
+	a, b diffSide
+	// firstKey is the first key stored in either subtree, see KeyOrder.
+	firstKey Prefix
+}
+
+// pair pairs the subtrees in as and bs, both sorted bytewise, returning them
+// in key order. They all continue from d.key.
+func (d *differ) pair(as, bs []diffSide) []diffFork {
+	forks := make([]diffFork, 0, len(as)+len(bs))
+	for len(as) != 0 || len(bs) != 0 {
+		switch {
+		case len(bs) == 0 || len(as) != 0 && as[0].first() < bs[0].first():
+			forks = append(forks, diffFork{a: as[0]})
+			as = as[1:]
+		case len(as) == 0 || bs[0].first() < as[0].first():
+			forks = append(forks, diffFork{b: bs[0]})
+			bs = bs[1:]
+		default:
+			forks = append(forks, diffFork{a: as[0], b: bs[0]})
+			as, bs = as[1:], bs[1:]
+		}
+	}
+	if d.less == nil || len(forks) < 2 {
+		return forks
+	}
+
+	// The subtrees are ordered as they would be in a trie containing both.
+	for i := range forks {
+		fork := &forks[i]
+		for _, side := range []diffSide{fork.a, fork.b} {
+			if side.node == nil {
+				continue
+			}
+			key := append(d.key[:len(d.key):len(d.key)], side.node.prefix[side.offset:]...)
+			if key = side.node.firstKey(key); fork.firstKey == nil || lessKey(d.less, key, fork.firstKey) {
+				fork.firstKey = key
+			}
+		}
+	}
+	sort.SliceStable(forks, func(i, j int) bool {
+		return lessKey(d.less, forks[i].firstKey, forks[j].firstKey)
+	})
+	return forks
+}
+
+// only reports all items in the subtree rooted at node, minus the first
+// offset bytes of node.prefix, as kind.
+func (d *differ) only(node *Trie, offset int, kind DiffKind) error {
+	key := append(d.key[:len(d.key):len(d.key)], node.prefix[offset:]...)
+	return node.walk(key, func(key Prefix, item Item) error {
+		if kind == DiffAdded {
+			return d.visitor(key, kind, nil, item)
+		}
+		return d.visitor(key, kind, item, nil)
+	})
+}
+
+// fingerprintItem returns the fingerprint of a node storing item
+// and having no children.
+func fingerprintItem(hash func(item Item) uint64, item Item) uint64 {
+	if item == nil {
+		return 0
+	}
+	return mix64(hash(item) ^ 0x9e3779b97f4a7c15)
+}
+
+// fingerprintChild returns what child adds to the fingerprint of its parent.
+// The fingerprint of a node does not cover its own prefix, that is covered
+// by the parent, so that compacting the node does not change it.
+func fingerprintChild(child *Trie) uint64 {
+	// FNV-1a over the prefix, starting from the child fingerprint.
+	h := child.fingerprint ^ 0xcbf29ce484222325
+	for _, b := range child.prefix {
+		h = (h ^ uint64(b)) * 0x100000001b3
+	}
+	return mix64(h ^ uint64(len(child.prefix)))
+}
+
+// mix64 is the finalizer of SplitMix64, a bijection scattering the bits.
+func mix64(x uint64) uint64 {
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// Tests -----------------------------------------------------------------------
+
+func TestDiff(t *testing.T) {
+	a := trieOf(map[string]Item{"Pepa": 1, "Pepa Zdepa": 2, "Honza": 3, "Jenik": 4})
+	b := trieOf(map[string]Item{"Pepa": 1, "Pepa Kuchar": 20, "Jenik": 30, "Hon": 40})
+
+	var diffs []string
+	if err := Diff(a, b, nil, func(key Prefix, kind DiffKind, old, new Item) error {
+		diffs = append(diffs, fmt.Sprintf("%v %s %v %v", kind, key, old, new))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"added Hon <nil> 40",
+		"removed Honza 3 <nil>",
+		"changed Jenik 4 30",
+		"added Pepa Kuchar <nil> 20",
+		"removed Pepa Zdepa 2 <nil>",
+	}
+	if !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("Unexpected diff, expected=%q, got=%q", expected, diffs)
+	}
+}
+
+func TestDiff_Eq(t *testing.T) {
+	a := trieOf(map[string]Item{"Pepa": 1, "Honza": 2})
+	b := trieOf(map[string]Item{"Pepa": 3, "Honza": 5})
+
+	odd := func(old, new Item) bool {
+		return old.(int)%2 == new.(int)%2
+	}
+
+	var changed []string
+	Diff(a, b, odd, func(key Prefix, kind DiffKind, old, new Item) error {
+		changed = append(changed, string(key))
+		return nil
+	})
+	if !reflect.DeepEqual(changed, []string{"Honza"}) {
+		t.Errorf("Unexpected keys changed: %q", changed)
+	}
+}
+
+func TestDiff_Identical(t *testing.T) {
+	a := trieOf(map[string]Item{"Pepa": 1, "Honza": 2})
+
+	if err := Diff(a, a, func(old, new Item) bool {
+		t.Error("Items compared when diffing a trie with itself")
+		return true
+	}, func(key Prefix, kind DiffKind, old, new Item) error {
+		t.Errorf("Unexpected diff: %v %q", kind, key)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiff_Fingerprints(t *testing.T) {
+	items := make(map[string]Item)
+	for i := 0; i < 1000; i++ {
+		items["Pepa"+strconv.Itoa(i)] = i
+	}
+
+	for _, options := range [][]Option{nil, {Fingerprints(hashInt)}} {
+		a := trieOf(items, options...)
+		b := a.Clone()
+		b.Set(Prefix("Pepa123"), -1)
+		b.Delete(Prefix("Pepa500"))
+		b.Insert(Prefix("Pepa Zdepa"), 0)
+
+		compared := 0
+		var diffs []string
+		if err := Diff(a, b, func(old, new Item) bool {
+			compared++
+			return old == new
+		}, func(key Prefix, kind DiffKind, old, new Item) error {
+			diffs = append(diffs, fmt.Sprintf("%v %s %v %v", kind, key, old, new))
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []string{
+			"added Pepa Zdepa <nil> 0",
+			"changed Pepa123 123 -1",
+			"removed Pepa500 500 <nil>",
+		}
+		if !reflect.DeepEqual(diffs, expected) {
+			t.Errorf("Unexpected diff, expected=%q, got=%q", expected, diffs)
+		}
+
+		// Without fingerprints all the items are compared, with them
+		// only the items on the paths to the keys modified.
+		if options == nil && compared < 990 {
+			t.Errorf("Unexpected number of items compared, expected>=990, got=%v", compared)
+		}
+		if options != nil && compared > 10 {
+			t.Errorf("Unexpected number of items compared, expected<=10, got=%v", compared)
+		}
+	}
+}
+
+func TestDiff_ReturnError(t *testing.T) {
+	a := trieOf(map[string]Item{"Pepa": 1, "Honza": 2})
+	b := NewTrie()
+
+	someErr := errors.New("Something exploded")
+	calls := 0
+	if err := Diff(a, b, nil, func(key Prefix, kind DiffKind, old, new Item) error {
+		calls++
+		return someErr
+	}); err != someErr {
+		t.Errorf("Unexpected error, expected=%v, got=%v", someErr, err)
+	}
+	if calls != 1 {
+		t.Errorf("Visitor called %v times after returning an error", calls)
+	}
+}
+
+func TestDiff_Random(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	randomItems := func() map[string]Item {
+		items := make(map[string]Item)
+		for i := 0; i < 200; i++ {
+			key := make([]byte, rnd.Intn(10))
+			for j := range key {
+				key[j] = "abc"[rnd.Intn(3)]
+			}
+			items[string(key)] = rnd.Intn(3)
+		}
+		return items
+	}
+
+	for round := 0; round < 50; round++ {
+		// Every other round the shared subtrees are skipped.
+		var options []Option
+		if round%2 == 1 {
+			options = append(options, Fingerprints(hashInt))
+		}
+
+		oldItems, newItems := randomItems(), randomItems()
+		a := trieOf(oldItems, append(options, MaxPrefixPerNode(3))...)
+		b := trieOf(newItems, options...)
+
+		var expected []string
+		for key, old := range oldItems {
+			if new, ok := newItems[key]; !ok {
+				expected = append(expected, fmt.Sprintf("%q %v", key, DiffRemoved))
+			} else if new != old {
+				expected = append(expected, fmt.Sprintf("%q %v", key, DiffChanged))
+			}
+		}
+		for key := range newItems {
+			if _, ok := oldItems[key]; !ok {
+				expected = append(expected, fmt.Sprintf("%q %v", key, DiffAdded))
+			}
+		}
+		sort.Strings(expected)
+
+		var diffs []string
+		Diff(a, b, nil, func(key Prefix, kind DiffKind, old, new Item) error {
+			diffs = append(diffs, fmt.Sprintf("%q %v", key, kind))
+			return nil
+		})
+		if !sort.StringsAreSorted(diffs) {
+			t.Errorf("Keys not reported in alphabetical order: %q", diffs)
+		}
+		sort.Strings(diffs)
+		if !reflect.DeepEqual(diffs, expected) {
+			t.Fatalf("Unexpected diff, expected=%q, got=%q", expected, diffs)
+		}
+	}
+}
+
+// Helpers ---------------------------------------------------------------------
+
+func hashInt(item Item) uint64 {
+	return uint64(item.(int))
+}
@@ -0,0 +1,226 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+// Package domains provides matching of hostnames against domain rules
+// with wildcards, built on top of patricia.Trie.
+package domains
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/tchap/go-patricia/v2/patricia"
+)
+
+//------------------------------------------------------------------------------
+// Matcher
+//------------------------------------------------------------------------------
+
+// Matcher stores domain rules and finds the most specific rule for a hostname.
+//
+// A rule is either exact, e.g. example.com, matching only the domain itself,
+// or a wildcard, e.g. *.example.com, matching all the subdomains of the domain
+// at any depth, but not the domain itself.
+//
+// The rules are stored in a trie using patricia.ReverseKeys, every domain
+// prefixed with a dot. The dots mark the label boundaries, so the suffixes
+// of a hostname stored in the trie are exactly its parent domains.
+//
+// Matcher is not thread-safe.
+type Matcher struct {
+	trie     *patricia.Trie
+	suffixes PublicSuffixList
+	len      int
+}
+
+// PublicSuffixList provides the public suffix of a domain. It is the same
+// interface as net/http/cookiejar.PublicSuffixList, so the implementation
+// in golang.org/x/net/publicsuffix can be used directly.
+type PublicSuffixList interface {
+	// PublicSuffix returns the public suffix of domain.
+	PublicSuffix(domain string) string
+	// String returns a description of the source of this public suffix list.
+	String() string
+}
+
+// Rule is a rule matching a hostname.
+type Rule struct {
+	// Pattern is the normalized pattern the rule was added with.
+	Pattern string
+	Item    patricia.Item
+}
+
+// Wildcard returns true for wildcard rules.
+func (rule Rule) Wildcard() bool {
+	return strings.HasPrefix(rule.Pattern, "*.")
+}
+
+// rules is what is stored in the trie for every domain.
+type rules struct {
+	exact    *Rule
+	wildcard *Rule
+}
+
+func (r *rules) get(wildcard bool) **Rule {
+	if wildcard {
+		return &r.wildcard
+	}
+	return &r.exact
+}
+
+// Public API ------------------------------------------------------------------
+
+type Option func(*Matcher)
+
+// Matcher constructor.
+func New(options ...Option) *Matcher {
+	matcher := &Matcher{
+		trie: patricia.NewTrie(patricia.ReverseKeys()),
+	}
+
+	for _, opt := range options {
+		opt(matcher)
+	}
+
+	return matcher
+}
+
+// PublicSuffixes makes the matcher reject wildcard rules covering a public
+// suffix as a whole, e.g. *.co.uk, which would match all the sites
+// registered under the suffix.
+func PublicSuffixes(list PublicSuffixList) Option {
+	return func(matcher *Matcher) {
+		matcher.suffixes = list
+	}
+}
+
+// Add adds a rule for pattern, which is either a domain or a domain
+// with *. prepended. Patterns are case-insensitive, a trailing dot is ignored.
+// In case the rule exists already, only the item is replaced.
+//
+// ErrInvalidPattern is returned when pattern is not a valid pattern,
+// ErrPublicSuffix when the wildcard covers a public suffix.
+func (matcher *Matcher) Add(pattern string, item patricia.Item) error {
+	domain, wildcard, ok := parsePattern(pattern)
+	if !ok {
+		return ErrInvalidPattern
+	}
+	if wildcard && matcher.suffixes != nil && matcher.suffixes.PublicSuffix(domain) == domain {
+		return ErrPublicSuffix
+	}
+
+	rule := &Rule{
+		Pattern: domain,
+		Item:    item,
+	}
+	if wildcard {
+		rule.Pattern = "*." + domain
+	}
+
+	matcher.trie.Update(domainKey(domain), func(old patricia.Item, exists bool) (patricia.Item, bool) {
+		r, _ := old.(*rules)
+		if r == nil {
+			r = &rules{}
+		}
+		if slot := r.get(wildcard); *slot == nil {
+			*slot = rule
+			matcher.len++
+		} else {
+			(*slot).Item = item
+		}
+		return r, true
+	})
+	return nil
+}
+
+// Remove removes the rule for pattern.
+//
+// True is returned if the rule was present.
+func (matcher *Matcher) Remove(pattern string) (removed bool) {
+	domain, wildcard, ok := parsePattern(pattern)
+	if !ok {
+		return false
+	}
+
+	matcher.trie.Update(domainKey(domain), func(old patricia.Item, exists bool) (patricia.Item, bool) {
+		if !exists {
+			return nil, false
+		}
+		r := old.(*rules)
+		if slot := r.get(wildcard); *slot != nil {
+			*slot = nil
+			matcher.len--
+			removed = true
+		}
+		return r, r.exact != nil || r.wildcard != nil
+	})
+	return
+}
+
+// Match returns the most specific rule matching host, which is the exact rule
+// for host if present, otherwise the wildcard rule for the closest parent
+// domain of host.
+func (matcher *Matcher) Match(host string) (rule Rule, ok bool) {
+	domain, wildcard, valid := parsePattern(host)
+	if !valid || wildcard {
+		return Rule{}, false
+	}
+
+	key := domainKey(domain)
+	var best *Rule
+	matcher.trie.VisitPrefixes(key, func(prefix patricia.Prefix, item patricia.Item) error {
+		r := item.(*rules)
+		switch {
+		case len(prefix) == len(key) && r.exact != nil:
+			best = r.exact
+		case len(prefix) < len(key) && r.wildcard != nil:
+			best = r.wildcard
+		}
+		return nil
+	})
+
+	if best == nil {
+		return Rule{}, false
+	}
+	return *best, true
+}
+
+// Len returns the number of rules stored.
+func (matcher *Matcher) Len() int {
+	return matcher.len
+}
+
+// Internal helper methods -----------------------------------------------------
+
+// parsePattern normalizes pattern, splitting off the leading wildcard label.
+func parsePattern(pattern string) (domain string, wildcard bool, ok bool) {
+	domain = strings.ToLower(strings.TrimSuffix(pattern, "."))
+	if strings.HasPrefix(domain, "*.") {
+		domain = domain[2:]
+		wildcard = true
+	}
+
+	if domain == "" {
+		return "", false, false
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" || strings.Contains(label, "*") {
+			return "", false, false
+		}
+	}
+	return domain, wildcard, true
+}
+
+// domainKey returns the key domain is stored under.
+func domainKey(domain string) patricia.Prefix {
+	return patricia.Prefix("." + domain)
+}
+
+// Errors ----------------------------------------------------------------------
+
+var (
+	ErrInvalidPattern = errors.New("Invalid domain pattern")
+	ErrPublicSuffix   = errors.New("Wildcard covering a public suffix")
+)
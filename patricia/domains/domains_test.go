@@ -0,0 +1,138 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package domains
+
+import (
+	"strings"
+	"testing"
+)
+
+// Tests -----------------------------------------------------------------------
+
+func TestMatcher_Match(t *testing.T) {
+	matcher := New()
+
+	patterns := []string{
+		"example.com",
+		"*.example.com",
+		"api.example.com",
+		"*.internal.example.com",
+		"Example.ORG.",
+	}
+	for i, pattern := range patterns {
+		if err := matcher.Add(pattern, i); err != nil {
+			t.Fatalf("Failed to add %q: %v", pattern, err)
+		}
+	}
+	if n := matcher.Len(); n != len(patterns) {
+		t.Errorf("Unexpected length, expected=%v, got=%v", len(patterns), n)
+	}
+
+	cases := []struct {
+		host    string
+		pattern string
+	}{
+		{"example.com", "example.com"},
+		{"www.example.com", "*.example.com"},
+		{"a.b.example.com", "*.example.com"},
+		{"api.example.com", "api.example.com"},
+		{"v1.api.example.com", "*.example.com"},
+		{"db.internal.example.com", "*.internal.example.com"},
+		{"internal.example.com", "*.example.com"},
+		{"EXAMPLE.org", "example.org"},
+		{"www.example.org", ""},
+		{"badexample.com", ""},
+		{"com", ""},
+		{"a..example.com", ""},
+	}
+	for _, c := range cases {
+		rule, ok := matcher.Match(c.host)
+		if ok != (c.pattern != "") || rule.Pattern != c.pattern {
+			t.Errorf("Unexpected rule, host=%q, expected=%q, got=%q", c.host, c.pattern, rule.Pattern)
+		}
+	}
+
+	rule, _ := matcher.Match("www.example.com")
+	if !rule.Wildcard() || rule.Item != 1 {
+		t.Errorf("Unexpected rule: %+v", rule)
+	}
+}
+
+func TestMatcher_AddRemove(t *testing.T) {
+	matcher := New()
+
+	matcher.Add("example.com", 1)
+	matcher.Add("*.example.com", 2)
+	matcher.Add("EXAMPLE.com", 3)
+	if n := matcher.Len(); n != 2 {
+		t.Errorf("Unexpected length, expected=2, got=%v", n)
+	}
+	if rule, _ := matcher.Match("example.com"); rule.Item != 3 {
+		t.Errorf("Item not replaced, got=%v", rule.Item)
+	}
+
+	if !matcher.Remove("*.example.com") {
+		t.Error("Remove failed")
+	}
+	if matcher.Remove("*.example.com") {
+		t.Error("Extra remove succeeded")
+	}
+	if _, ok := matcher.Match("www.example.com"); ok {
+		t.Error("Removed wildcard still matching")
+	}
+	if _, ok := matcher.Match("example.com"); !ok {
+		t.Error("Exact rule removed along with the wildcard")
+	}
+
+	matcher.Remove("example.com")
+	if n := matcher.Len(); n != 0 {
+		t.Errorf("Unexpected length, expected=0, got=%v", n)
+	}
+}
+
+func TestMatcher_InvalidPatterns(t *testing.T) {
+	matcher := New()
+	for _, pattern := range []string{"", ".", "*", "*.", "a..b", "www.*.example.com", "**.example.com", ".example.com"} {
+		if err := matcher.Add(pattern, 1); err != ErrInvalidPattern {
+			t.Errorf("Unexpected error, pattern=%q, expected=%v, got=%v", pattern, ErrInvalidPattern, err)
+		}
+	}
+}
+
+func TestMatcher_PublicSuffixes(t *testing.T) {
+	matcher := New(PublicSuffixes(testSuffixList{"com", "uk", "co.uk"}))
+
+	for _, pattern := range []string{"*.com", "*.co.uk"} {
+		if err := matcher.Add(pattern, 1); err != ErrPublicSuffix {
+			t.Errorf("Unexpected error, pattern=%q, expected=%v, got=%v", pattern, ErrPublicSuffix, err)
+		}
+	}
+	for _, pattern := range []string{"*.example.co.uk", "co.uk"} {
+		if err := matcher.Add(pattern, 1); err != nil {
+			t.Errorf("Unexpected error, pattern=%q: %v", pattern, err)
+		}
+	}
+}
+
+// Helpers ---------------------------------------------------------------------
+
+// testSuffixList implements the default rule of the public suffix list,
+// the suffix being the longest suffix listed or the last label.
+type testSuffixList []string
+
+func (list testSuffixList) PublicSuffix(domain string) string {
+	suffix := domain[strings.LastIndex(domain, ".")+1:]
+	for _, s := range list {
+		if (domain == s || strings.HasSuffix(domain, "."+s)) && len(s) > len(suffix) {
+			suffix = s
+		}
+	}
+	return suffix
+}
+
+func (list testSuffixList) String() string {
+	return "test"
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+//------------------------------------------------------------------------------
+// Deleting while visiting
+//------------------------------------------------------------------------------
+
+// VisitDeleteFunc works much like VisitorFunc, the item visited is deleted
+// in case remove is true, no matter what error is returned.
+type VisitDeleteFunc func(prefix Prefix, item Item) (remove bool, err error)
+
+// SubtreeFunc is passed the summary of a subtree, see Aggregation. It returns
+// whether the subtree is to be visited at all and whether all the items in it
+// are to be deleted without visiting them, which takes precedence.
+// See PruneSubtrees.
+type SubtreeFunc func(summary Summary) (visit, removeAll bool)
+
+// VisitAndDelete calls visitor on every node containing a non-nil item
+// in key order, deleting the items visitor asks for. Calling Delete
+// from a VisitorFunc passed to Visit is not safe, this is the way to do it.
+//
+// The trie is compacted on the way back up as the traversal goes, so deleting
+// is done in a single pass. Errors are handled the same way as in Visit, the
+// items deleted so far remain deleted. The number of items deleted is returned.
+func (trie *Trie) VisitAndDelete(visitor VisitDeleteFunc) (removed int, err error) {
+	return trie.visitAndDelete(nil, visitor)
+}
+
+// PruneSubtrees works like VisitAndDelete, but subtree is consulted first for
+// every subtree, the whole trie included, before any of its items is visited.
+// It is passed the summary of the subtree computed by the Aggregator the trie
+// was created using, nil when there is none, see Aggregation.
+//
+// A subtree for which visit is false is skipped as a whole. A subtree for which
+// removeAll is true is unlinked without visiting the items in it, but its nodes
+// are still walked once to count the items deleted. Otherwise the item stored
+// in the subtree root is passed to visitor and its children are consulted
+// the same way.
+//
+// visitor can be nil, in which case the items visited are kept and only whole
+// subtrees are deleted. subtree can be nil as well, in which case all the items
+// are visited as in VisitAndDelete. Errors are handled as in VisitAndDelete.
+func (trie *Trie) PruneSubtrees(subtree SubtreeFunc, visitor VisitDeleteFunc) (removed int, err error) {
+	// Empty trie must be handled explicitly.
+	if trie.prefix == nil {
+		return 0, nil
+	}
+
+	if subtree != nil {
+		switch visit, removeAll := subtree(trie.summary); {
+		case removeAll:
+			removed = trie.size()
+			trie.unlinked()
+			trie.item = nil
+			trie.reset()
+			return removed, nil
+		case !visit:
+			return 0, nil
+		}
+	}
+	return trie.visitAndDelete(subtree, visitor)
+}
+
+// Filter deletes all items for which keep returns false, in a single pass.
+// The number of items deleted is returned.
+func (trie *Trie) Filter(keep func(prefix Prefix, item Item) bool) (removed int) {
+	removed, _ = trie.VisitAndDelete(func(prefix Prefix, item Item) (bool, error) {
+		return !keep(prefix, item), nil
+	})
+	return
+}
+
+// Internal helper methods -----------------------------------------------------
+
+func (trie *Trie) visitAndDelete(subtree SubtreeFunc, visitor VisitDeleteFunc) (removed int, err error) {
+	// Empty trie must be handled explicitly.
+	if trie.prefix == nil {
+		return 0, nil
+	}
+
+	if trie.reverseKeys && visitor != nil {
+		var buf Prefix
+		decoded := visitor
+		visitor = func(prefix Prefix, item Item) (bool, error) {
+			buf = trie.decodeKey(buf, prefix)
+			return decoded(buf, item)
+		}
+	}
+
+	p := &pruner{
+		subtree: subtree,
+		visitor: visitor,
+		key:     append(make(Prefix, 0, 32+len(trie.prefix)), trie.prefix...),
+	}
+	err = p.prune(trie)
+
+	// Tidy up the root, which must be kept in place.
+	// The summary of the compacted child covers the whole trie already.
+	if trie.empty() {
+		trie.reset()
+	} else if compacted := trie.compact(); compacted != trie {
+		*trie = *compacted
+	}
+
+	return p.removed, err
+}
+
+// pruner visits the trie depth-first, dropping empty nodes and compacting
+// the rest once their children are done.
+type pruner struct {
+	subtree SubtreeFunc
+	visitor VisitDeleteFunc
+	key     Prefix
+	removed int
+}
+
+// prune visits the subtree rooted at node, which represents p.key.
+func (p *pruner) prune(node *Trie) error {
+	// Runs once the children are done, no matter how the visit ends.
+	// p.key only grows past the node key in the meantime.
+	defer node.aggregate(p.key)
+
+	if node.item != nil && p.visitor != nil {
+		remove, err := p.visitor(node.visitKey(p.key), node.item)
+		if remove {
+			node.item = nil
+			node.original = nil
+			p.removed++
+		}
+		if err == SkipSubtree {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Children are removed and replaced on the go,
+	// iterate over a snapshot of the child list.
+	for _, child := range node.ordered(p.key) {
+		if p.subtree != nil {
+			visit, removeAll := p.subtree(child.summary)
+			if removeAll {
+				p.removed += child.size()
+				child.unlinked()
+				node.children.remove(child.prefix[0])
+				continue
+			}
+			if !visit {
+				continue
+			}
+		}
+
+		p.key = append(p.key, child.prefix...)
+		err := p.prune(child)
+		p.key = p.key[:len(p.key)-len(child.prefix)]
+
+		if child.empty() {
+			node.children.remove(child.prefix[0])
+		} else if compacted := child.compact(); compacted != child {
+			node.children.replace(child.prefix[0], compacted)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Tests -----------------------------------------------------------------------
+
+func TestTrie_VisitAndDelete(t *testing.T) {
+	trie := NewTrie()
+
+	data := []testData{
+		{"Pepa", 0, success},
+		{"Pepa Zdepa", 1, success},
+		{"Pepa Kuchar", 2, success},
+		{"Honza", 3, success},
+		{"Jenik", 4, success},
+	}
+
+	for _, v := range data {
+		t.Logf("INSERT prefix=%v, item=%v, success=%v", v.key, v.value, v.retVal)
+		if ok := trie.Insert([]byte(v.key), v.value); ok != v.retVal {
+			t.Fatalf("Unexpected return value, expected=%v, got=%v", v.retVal, ok)
+		}
+	}
+
+	var visited []string
+	removed, err := trie.VisitAndDelete(func(prefix Prefix, item Item) (bool, error) {
+		t.Logf("VISITING prefix=%q, item=%v", prefix, item)
+		visited = append(visited, string(prefix))
+		return item.(int)%2 == 0, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 3 {
+		t.Errorf("Unexpected number of items removed, expected=3, got=%v", removed)
+	}
+
+	expected := []string{"Honza", "Jenik", "Pepa", "Pepa Kuchar", "Pepa Zdepa"}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Errorf("Unexpected keys visited, expected=%q, got=%q", expected, visited)
+	}
+
+	expected = []string{"Honza", "Pepa Zdepa"}
+	if keys := visitedKeys(trie); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected keys left, expected=%q, got=%q", expected, keys)
+	}
+	checkNoEmptyLeaves(t, trie)
+}
+
+func TestTrie_VisitAndDeleteSkipSubtree(t *testing.T) {
+	trie := trieOf(map[string]Item{"Pepa": 0, "Pepa Zdepa": 1, "Honza": 2})
+
+	removed, err := trie.VisitAndDelete(func(prefix Prefix, item Item) (bool, error) {
+		if string(prefix) == "Pepa" {
+			return true, SkipSubtree
+		}
+		if string(prefix) == "Pepa Zdepa" {
+			t.Errorf("Unexpected prefix encountered, %q", prefix)
+		}
+		return false, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Errorf("Unexpected number of items removed, expected=1, got=%v", removed)
+	}
+
+	expected := []string{"Honza", "Pepa Zdepa"}
+	if keys := visitedKeys(trie); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected keys left, expected=%q, got=%q", expected, keys)
+	}
+}
+
+func TestTrie_VisitAndDeleteReturnError(t *testing.T) {
+	trie := trieOf(map[string]Item{"Pepa": 0, "Pepa Zdepa": 1, "Honza": 2})
+
+	someErr := errors.New("Something exploded")
+	removed, err := trie.VisitAndDelete(func(prefix Prefix, item Item) (bool, error) {
+		if string(prefix) == "Pepa" {
+			return true, someErr
+		}
+		return true, nil
+	})
+	if err != someErr {
+		t.Errorf("Unexpected error, expected=%v, got=%v", someErr, err)
+	}
+	if removed != 2 {
+		t.Errorf("Unexpected number of items removed, expected=2, got=%v", removed)
+	}
+
+	expected := []string{"Pepa Zdepa"}
+	if keys := visitedKeys(trie); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected keys left, expected=%q, got=%q", expected, keys)
+	}
+	checkNoEmptyLeaves(t, trie)
+}
+
+func TestTrie_FilterAll(t *testing.T) {
+	trie := trieOf(map[string]Item{"": 0, "Pepa": 1, "Pepa Zdepa": 2, "Honza": 3})
+
+	if removed := trie.Filter(func(Prefix, Item) bool { return false }); removed != 4 {
+		t.Errorf("Unexpected number of items removed, expected=4, got=%v", removed)
+	}
+	if trie.prefix != nil || !trie.empty() {
+		t.Errorf("Trie not reset:\n%s", trie.dump())
+	}
+
+	trie.Insert(Prefix("Jenik"), 4)
+	if item := trie.Get(Prefix("Jenik")); item != 4 {
+		t.Errorf("Unexpected item, expected=4, got=%v", item)
+	}
+}
+
+func TestTrie_PruneSubtrees(t *testing.T) {
+	trie := trieOf(map[string]Item{
+		"drop":       "drop",
+		"drop/a":     "drop",
+		"drop/b":     "drop",
+		"keep/a":     "keep",
+		"keep/b":     "keep",
+		"mixed":      "drop",
+		"mixed/drop": "drop",
+		"mixed/keep": "keep",
+	}, Aggregation(concatAggregator{}))
+
+	subtree := func(summary Summary) (visit, removeAll bool) {
+		s := "," + summary.(string) + ","
+		return strings.Contains(s, ",drop,"), !strings.Contains(s, ",keep,")
+	}
+
+	var visited []string
+	removed, err := trie.PruneSubtrees(subtree, func(prefix Prefix, item Item) (bool, error) {
+		visited = append(visited, string(prefix))
+		return item == "drop", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 5 {
+		t.Errorf("Unexpected number of items removed, expected=5, got=%v", removed)
+	}
+
+	// Only the items in the subtrees mixing both are visited.
+	if expected := []string{"mixed"}; !reflect.DeepEqual(visited, expected) {
+		t.Errorf("Unexpected keys visited, expected=%q, got=%q", expected, visited)
+	}
+
+	expected := map[string]Item{"keep/a": "keep", "keep/b": "keep", "mixed/keep": "keep"}
+	if items := itemsOf(trie); !reflect.DeepEqual(items, expected) {
+		t.Errorf("Unexpected items, expected=%v, got=%v", expected, items)
+	}
+	checkNoEmptyLeaves(t, trie)
+	if summary := trie.AggregateSubtree(Prefix("")); summary != "keep,keep,keep" {
+		t.Errorf("Unexpected summary: %v", summary)
+	}
+
+	// Dropping everything resets the trie.
+	removed, _ = trie.PruneSubtrees(func(Summary) (bool, bool) {
+		return true, true
+	}, nil)
+	if removed != 3 || !trie.empty() {
+		t.Errorf("Trie not emptied, removed=%v", removed)
+	}
+}
+
+func TestTrie_PruneSubtreesNilVisitor(t *testing.T) {
+	trie := trieOf(map[string]Item{
+		"drop/a":     "drop",
+		"drop/b":     "drop",
+		"mixed":      "drop",
+		"mixed/drop": "drop",
+		"mixed/keep": "keep",
+	}, Aggregation(concatAggregator{}))
+
+	// Without a visitor only the subtrees with nothing to keep are deleted.
+	removed, err := trie.PruneSubtrees(func(summary Summary) (visit, removeAll bool) {
+		return true, !strings.Contains(","+summary.(string)+",", ",keep,")
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 3 {
+		t.Errorf("Unexpected number of items removed, expected=3, got=%v", removed)
+	}
+
+	expected := map[string]Item{"mixed": "drop", "mixed/keep": "keep"}
+	if items := itemsOf(trie); !reflect.DeepEqual(items, expected) {
+		t.Errorf("Unexpected items, expected=%v, got=%v", expected, items)
+	}
+	checkNoEmptyLeaves(t, trie)
+
+	// Without either function nothing is deleted.
+	if removed, _ := trie.PruneSubtrees(nil, nil); removed != 0 {
+		t.Errorf("Unexpected number of items removed, expected=0, got=%v", removed)
+	}
+	if items := itemsOf(trie); !reflect.DeepEqual(items, expected) {
+		t.Errorf("Unexpected items, expected=%v, got=%v", expected, items)
+	}
+}
+
+func TestTrie_FilterRandom(t *testing.T) {
+	items := make(map[string]Item)
+	for i := 0; i < 2000; i++ {
+		items[strconv.Itoa(rand.Intn(100000))] = i
+	}
+
+	for _, options := range [][]Option{nil, {MaxPrefixPerNode(2), MaxChildrenPerSparseNode(3)}} {
+		trie := trieOf(items, options...)
+		trie.Filter(func(prefix Prefix, item Item) bool {
+			return item.(int)%3 == 0
+		})
+
+		expected := make(map[string]Item)
+		for key, item := range items {
+			if item.(int)%3 == 0 {
+				expected[key] = item
+			}
+		}
+		if left := itemsOf(trie); !reflect.DeepEqual(left, expected) {
+			t.Errorf("Unexpected items left, expected=%v, got=%v", expected, left)
+		}
+		for key, item := range expected {
+			if got := trie.Get(Prefix(key)); got != item {
+				t.Errorf("Unexpected item, prefix=%v, expected=%v, got=%v", key, item, got)
+			}
+		}
+		checkNoEmptyLeaves(t, trie)
+		checkMaxPrefixPerNode(t, trie)
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+import "sort"
+
+//------------------------------------------------------------------------------
+// Secondary indexes
+//------------------------------------------------------------------------------
+
+// IndexFunc returns the keys item is to be found under in a secondary index.
+// It must be deterministic, returning the same keys for the same item,
+// since it is called again to find the keys once the item is replaced.
+type IndexFunc func(item Item) []Prefix
+
+// IndexedTrie is a trie storing items by their primary keys, keeping any number
+// of named secondary indexes consistent with the items stored.
+//
+// Every index is a trie of its own, mapping the keys returned by its IndexFunc
+// to the primary keys, so the indexes can be searched by prefix as well.
+//
+// IndexedTrie is not thread-safe.
+type IndexedTrie struct {
+	primary *Trie
+	indexes map[string]*secondaryIndex
+}
+
+// Public API ------------------------------------------------------------------
+
+// NewIndexedTrie creates an IndexedTrie without any indexes. The options
+// apply to the primary trie, the indexes always use the default options.
+func NewIndexedTrie(options ...Option) *IndexedTrie {
+	return &IndexedTrie{
+		primary: NewTrie(options...),
+		indexes: make(map[string]*secondaryIndex),
+	}
+}
+
+// AddIndex adds an index called name, indexing all the items stored already.
+// An index with the same name is replaced.
+func (trie *IndexedTrie) AddIndex(name string, fn IndexFunc) {
+	index := &secondaryIndex{
+		fn:   fn,
+		trie: NewTrie(),
+	}
+	trie.primary.Visit(func(key Prefix, item Item) error {
+		index.add(trie.primary.encodeKey(key), key, item)
+		return nil
+	})
+	trie.indexes[name] = index
+}
+
+// DropIndex removes the index called name.
+func (trie *IndexedTrie) DropIndex(name string) {
+	delete(trie.indexes, name)
+}
+
+// Insert works like Trie.Insert, indexing the item when inserted.
+func (trie *IndexedTrie) Insert(key Prefix, item Item) (inserted bool) {
+	if inserted = trie.primary.Insert(key, item); inserted && item != nil {
+		trie.index(key, item)
+	}
+	return
+}
+
+// Set works like Trie.Set, reindexing the item replaced.
+// Setting nil deletes the item, as usual.
+func (trie *IndexedTrie) Set(key Prefix, item Item) {
+	if old, replaced := trie.primary.Swap(key, item); replaced {
+		trie.unindex(key, old)
+	}
+	if item != nil {
+		trie.index(key, item)
+	}
+}
+
+// Get returns the item stored under the primary key.
+func (trie *IndexedTrie) Get(key Prefix) Item {
+	return trie.primary.Get(key)
+}
+
+// Delete deletes the item stored under the primary key from the trie
+// and all the indexes.
+func (trie *IndexedTrie) Delete(key Prefix) (deleted bool) {
+	item, deleted := trie.primary.Remove(key)
+	if deleted {
+		trie.unindex(key, item)
+	}
+	return
+}
+
+// DeleteSubtree deletes all the items with primary keys starting with prefix.
+func (trie *IndexedTrie) DeleteSubtree(prefix Prefix) (deleted bool) {
+	return trie.primary.RemoveSubtree(prefix, func(key Prefix, item Item) {
+		trie.unindex(key, item)
+	}) != 0
+}
+
+// Visit visits the items by their primary keys, see Trie.Visit.
+func (trie *IndexedTrie) Visit(visitor VisitorFunc) error {
+	return trie.primary.Visit(visitor)
+}
+
+// VisitSubtree visits the items with primary keys starting with prefix,
+// see Trie.VisitSubtree.
+func (trie *IndexedTrie) VisitSubtree(prefix Prefix, visitor VisitorFunc) error {
+	return trie.primary.VisitSubtree(prefix, visitor)
+}
+
+// VisitIndex calls visitor on every item with a key in the index called name
+// starting with prefix, passing it the primary key of the item. The items
+// are visited in the order of the index keys, the items sharing an index key
+// in the order of their primary keys. An item is visited once per index key
+// matching.
+//
+// ErrUnknownIndex is returned when there is no index called name. Otherwise
+// the errors are handled as in Trie.VisitSubtree, SkipSubtree skipping
+// the rest of the items sharing the index key.
+func (trie *IndexedTrie) VisitIndex(name string, prefix Prefix, visitor VisitorFunc) error {
+	index, ok := trie.indexes[name]
+	if !ok {
+		return ErrUnknownIndex
+	}
+	return index.trie.VisitSubtree(prefix, func(_ Prefix, item Item) error {
+		for _, entry := range item.(indexEntries) {
+			if err := visitor(entry.key, entry.item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Internal helper methods -----------------------------------------------------
+
+func (trie *IndexedTrie) index(key Prefix, item Item) {
+	stored := trie.primary.encodeKey(key)
+	for _, index := range trie.indexes {
+		index.add(stored, key, item)
+	}
+}
+
+func (trie *IndexedTrie) unindex(key Prefix, item Item) {
+	stored := trie.primary.encodeKey(key)
+	for _, index := range trie.indexes {
+		index.remove(stored, item)
+	}
+}
+
+type secondaryIndex struct {
+	fn   IndexFunc
+	trie *Trie
+}
+
+// indexEntry is an item found under an index key. stored is the primary key
+// as stored in the primary trie, key the one to be passed to visitors.
+type indexEntry struct {
+	stored Prefix
+	key    Prefix
+	item   Item
+}
+
+// indexEntries are stored in the index trie, sorted by the stored keys.
+type indexEntries []indexEntry
+
+func (index *secondaryIndex) add(stored, key Prefix, item Item) {
+	entry := indexEntry{
+		stored: append(make(Prefix, 0, len(stored)), stored...),
+		key:    append(make(Prefix, 0, len(key)), key...),
+		item:   item,
+	}
+	for _, indexKey := range index.fn(item) {
+		index.trie.Update(indexKey, func(current Item, exists bool) (Item, bool) {
+			var entries indexEntries
+			if exists {
+				entries = current.(indexEntries)
+			}
+			i := entries.search(stored)
+			if i < len(entries) && string(entries[i].stored) == string(stored) {
+				// The index key was returned twice for the same item.
+				return entries, true
+			}
+			entries = append(entries, indexEntry{})
+			copy(entries[i+1:], entries[i:])
+			entries[i] = entry
+			return entries, true
+		})
+	}
+}
+
+func (index *secondaryIndex) remove(stored Prefix, item Item) {
+	for _, indexKey := range index.fn(item) {
+		index.trie.Update(indexKey, func(current Item, exists bool) (Item, bool) {
+			if !exists {
+				return nil, false
+			}
+			entries := current.(indexEntries)
+			i := entries.search(stored)
+			if i == len(entries) || string(entries[i].stored) != string(stored) {
+				return entries, true
+			}
+			entries = append(entries[:i], entries[i+1:]...)
+			return entries, len(entries) != 0
+		})
+	}
+}
+
+// search returns the position of the entry for stored,
+// which is where it is to be inserted when missing.
+func (entries indexEntries) search(stored Prefix) int {
+	return sort.Search(len(entries), func(i int) bool {
+		return string(entries[i].stored) >= string(stored)
+	})
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Tests -----------------------------------------------------------------------
+
+type person struct {
+	name string
+	city string
+	tags []string
+}
+
+func byCity(item Item) []Prefix {
+	return []Prefix{Prefix(item.(person).city)}
+}
+
+func byTag(item Item) []Prefix {
+	var keys []Prefix
+	for _, tag := range item.(person).tags {
+		keys = append(keys, Prefix(tag))
+	}
+	return keys
+}
+
+func TestIndexedTrie(t *testing.T) {
+	trie := NewIndexedTrie()
+	trie.AddIndex("city", byCity)
+
+	people := []person{
+		{"Pepa Novak", "Praha", []string{"admin"}},
+		{"Pepa Zdepa", "Plzen", []string{"user", "admin", "user"}},
+		{"Karel Macha", "Praha", []string{"user"}},
+	}
+	for _, p := range people {
+		t.Logf("INSERT prefix=%v, item=%v", p.name, p)
+		if ok := trie.Insert(Prefix(p.name), p); !ok {
+			t.Fatalf("Insert failed, prefix=%v", p.name)
+		}
+	}
+
+	// Indexes added later index the items present already.
+	trie.AddIndex("tag", byTag)
+
+	checkIndex(t, trie, "city", "Pr", []string{"Karel Macha", "Pepa Novak"})
+	checkIndex(t, trie, "city", "P", []string{"Pepa Zdepa", "Karel Macha", "Pepa Novak"})
+	checkIndex(t, trie, "tag", "admin", []string{"Pepa Novak", "Pepa Zdepa"})
+
+	// Replacing an item moves it in the indexes.
+	trie.Set(Prefix("Pepa Novak"), person{"Pepa Novak", "Brno", []string{"user"}})
+	checkIndex(t, trie, "city", "Praha", []string{"Karel Macha"})
+	checkIndex(t, trie, "city", "Brno", []string{"Pepa Novak"})
+	checkIndex(t, trie, "tag", "admin", []string{"Pepa Zdepa"})
+	checkIndex(t, trie, "tag", "user", []string{"Karel Macha", "Pepa Novak", "Pepa Zdepa"})
+
+	// Insert does not replace anything, the indexes must stay as they are.
+	if trie.Insert(Prefix("Karel Macha"), person{"Karel Macha", "Brno", nil}) {
+		t.Error("Insert replaced an item")
+	}
+	checkIndex(t, trie, "city", "Brno", []string{"Pepa Novak"})
+
+	if !trie.Delete(Prefix("Pepa Zdepa")) {
+		t.Error("Delete failed")
+	}
+	checkIndex(t, trie, "tag", "", []string{"Karel Macha", "Pepa Novak"})
+
+	if !trie.DeleteSubtree(Prefix("Pepa")) {
+		t.Error("DeleteSubtree failed")
+	}
+	checkIndex(t, trie, "city", "", []string{"Karel Macha"})
+
+	if err := trie.VisitIndex("age", Prefix(""), nil); err != ErrUnknownIndex {
+		t.Errorf("Unexpected error, expected=%v, got=%v", ErrUnknownIndex, err)
+	}
+}
+
+func TestIndexedTrie_NilItem(t *testing.T) {
+	trie := NewIndexedTrie()
+	trie.AddIndex("city", byCity)
+
+	trie.Insert(Prefix("Honza"), nil)
+	trie.Set(Prefix("Pepa"), person{city: "Praha"})
+
+	// Setting nil deletes, the index functions must not see it.
+	trie.Set(Prefix("Pepa"), nil)
+	if item := trie.Get(Prefix("Pepa")); item != nil {
+		t.Errorf("Unexpected item, expected=<nil>, got=%v", item)
+	}
+	checkIndex(t, trie, "city", "", nil)
+}
+
+func TestIndexedTrie_EmptyKey(t *testing.T) {
+	trie := NewIndexedTrie()
+	trie.AddIndex("city", byCity)
+
+	trie.Set(Prefix(""), person{city: "Praha"})
+
+	// The index must hand out the empty key, not nil.
+	err := trie.VisitIndex("city", Prefix("Praha"), func(key Prefix, item Item) error {
+		if key == nil {
+			t.Error("Nil key passed to the visitor")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIndex(t, trie, "city", "", []string{""})
+}
+
+func TestIndexedTrie_CaseInsensitive(t *testing.T) {
+	trie := NewIndexedTrie(CaseInsensitive())
+	trie.AddIndex("city", byCity)
+
+	trie.Set(Prefix("Pepa"), person{city: "Praha"})
+	trie.Set(Prefix("PEPA"), person{city: "Brno"})
+	checkIndex(t, trie, "city", "", []string{"PEPA"})
+
+	trie.Delete(Prefix("pepa"))
+	checkIndex(t, trie, "city", "", nil)
+}
+
+// Helpers ---------------------------------------------------------------------
+
+func checkIndex(t *testing.T, trie *IndexedTrie, name, prefix string, expected []string) {
+	t.Helper()
+
+	var keys []string
+	err := trie.VisitIndex(name, Prefix(prefix), func(key Prefix, item Item) error {
+		if got := trie.Get(key); !reflect.DeepEqual(got, item) {
+			t.Errorf("Stale item in index %v, expected=%v, got=%v", name, got, item)
+		}
+		keys = append(keys, string(key))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected keys in index %v under %q, expected=%q, got=%q", name, prefix, expected, keys)
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+// Package keyenc provides order-preserving encodings of typed values
+// and tuples thereof, so that the bytewise order of the keys stored
+// in patricia.Trie matches the natural order of the values encoded.
+package keyenc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/tchap/go-patricia/v2/patricia"
+)
+
+//------------------------------------------------------------------------------
+// Tuple encoding
+//------------------------------------------------------------------------------
+
+// Tuple is a sequence of values encoded into a single key.
+//
+// Decoded tuples contain values of type string, []byte, int64, uint64, float64
+// and time.Time. Encoding accepts all the integer and float types as well.
+type Tuple []interface{}
+
+// TupleVisitorFunc is called by VisitTuplePrefix for every key decoded.
+type TupleVisitorFunc func(tuple Tuple, item patricia.Item) error
+
+// Every value is prefixed with a tag. Values of different types are ordered
+// by their tags, so e.g. all signed integers go before all unsigned ones.
+const (
+	tagBytes  byte = 0x01
+	tagString byte = 0x02
+	tagInt    byte = 0x03
+	tagUint   byte = 0x04
+	tagFloat  byte = 0x05
+	tagTime   byte = 0x06
+)
+
+// Strings and byte slices are terminated by a zero byte,
+// zero bytes within them are escaped as 0x00 0xff.
+const (
+	terminator byte = 0x00
+	escape     byte = 0xff
+)
+
+// Public API ------------------------------------------------------------------
+
+// Encode encodes the tuple consisting of values. Every value is encoded
+// in a self-delimiting way, so tuples are ordered element by element
+// and the encoding of a tuple is a prefix of the encoding of any tuple
+// extending it.
+//
+// ErrUnsupportedType is returned for values of other types than those listed
+// for Tuple. The key returned is never nil, even for an empty tuple.
+func Encode(values ...interface{}) (patricia.Prefix, error) {
+	key, err := Append(make(patricia.Prefix, 0, 16*len(values)), values...)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Append appends the encoding of the tuple consisting of values to dst.
+func Append(dst []byte, values ...interface{}) ([]byte, error) {
+	for _, value := range values {
+		switch v := value.(type) {
+		case string:
+			dst = AppendString(dst, v)
+		case []byte:
+			dst = AppendBytes(dst, v)
+		case int:
+			dst = AppendInt(dst, int64(v))
+		case int8:
+			dst = AppendInt(dst, int64(v))
+		case int16:
+			dst = AppendInt(dst, int64(v))
+		case int32:
+			dst = AppendInt(dst, int64(v))
+		case int64:
+			dst = AppendInt(dst, v)
+		case uint:
+			dst = AppendUint(dst, uint64(v))
+		case uint8:
+			dst = AppendUint(dst, uint64(v))
+		case uint16:
+			dst = AppendUint(dst, uint64(v))
+		case uint32:
+			dst = AppendUint(dst, uint64(v))
+		case uint64:
+			dst = AppendUint(dst, v)
+		case float32:
+			dst = AppendFloat(dst, float64(v))
+		case float64:
+			dst = AppendFloat(dst, v)
+		case time.Time:
+			dst = AppendTime(dst, v)
+		default:
+			return nil, fmt.Errorf("%w: %T", ErrUnsupportedType, value)
+		}
+	}
+	return dst, nil
+}
+
+// AppendString appends the encoding of s to dst.
+func AppendString(dst []byte, s string) []byte {
+	return appendEscaped(append(dst, tagString), s)
+}
+
+// AppendBytes appends the encoding of b to dst.
+func AppendBytes(dst []byte, b []byte) []byte {
+	return appendEscaped(append(dst, tagBytes), string(b))
+}
+
+// AppendInt appends the encoding of v to dst.
+func AppendInt(dst []byte, v int64) []byte {
+	return appendUint64(append(dst, tagInt), uint64(v)^1<<63)
+}
+
+// AppendUint appends the encoding of v to dst.
+func AppendUint(dst []byte, v uint64) []byte {
+	return appendUint64(append(dst, tagUint), v)
+}
+
+// AppendFloat appends the encoding of v to dst. Negative zero goes right
+// before positive zero, NaNs go to either end depending on their sign.
+func AppendFloat(dst []byte, v float64) []byte {
+	bits := math.Float64bits(v)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	return appendUint64(append(dst, tagFloat), bits)
+}
+
+// AppendTime appends the encoding of t to dst. The location is not encoded,
+// decoded times are always in UTC.
+func AppendTime(dst []byte, t time.Time) []byte {
+	dst = appendUint64(append(dst, tagTime), uint64(t.Unix())^1<<63)
+	return append(dst,
+		byte(t.Nanosecond()>>24), byte(t.Nanosecond()>>16),
+		byte(t.Nanosecond()>>8), byte(t.Nanosecond()))
+}
+
+// Decode decodes the tuple encoded in key. ErrInvalidKey is returned
+// when key is not a valid encoding.
+func Decode(key []byte) (Tuple, error) {
+	tuple := Tuple{}
+	for len(key) != 0 {
+		var (
+			value interface{}
+			n     int
+			err   error
+		)
+		switch tag, rest := key[0], key[1:]; tag {
+		case tagString:
+			var b []byte
+			b, n, err = decodeEscaped(rest)
+			value = string(b)
+		case tagBytes:
+			value, n, err = decodeEscaped(rest)
+		case tagInt:
+			var v uint64
+			v, n, err = decodeUint64(rest)
+			value = int64(v ^ 1<<63)
+		case tagUint:
+			value, n, err = decodeUint64(rest)
+		case tagFloat:
+			var bits uint64
+			bits, n, err = decodeUint64(rest)
+			if bits&(1<<63) != 0 {
+				bits &^= 1 << 63
+			} else {
+				bits = ^bits
+			}
+			value = math.Float64frombits(bits)
+		case tagTime:
+			if len(rest) < 12 {
+				return nil, ErrInvalidKey
+			}
+			sec := int64(binary.BigEndian.Uint64(rest) ^ 1<<63)
+			nsec := int64(binary.BigEndian.Uint32(rest[8:]))
+			value, n = time.Unix(sec, nsec).UTC(), 12
+		default:
+			return nil, ErrInvalidKey
+		}
+		if err != nil {
+			return nil, err
+		}
+		tuple = append(tuple, value)
+		key = key[1+n:]
+	}
+	return tuple, nil
+}
+
+// VisitTuplePrefix calls visitor on every key in trie that starts with
+// the tuple prefix, decoded into a tuple, in the order of the tuples.
+// All the keys in the subtree must be encoded tuples, otherwise ErrInvalidKey
+// is returned. SkipSubtree works the same way it does for Trie.VisitSubtree.
+//
+// The trie must not be created using options altering the keys or their
+// order, e.g. patricia.ReverseKeys or patricia.KeyOrder.
+func VisitTuplePrefix(trie *patricia.Trie, prefix Tuple, visitor TupleVisitorFunc) error {
+	key, err := Encode(prefix...)
+	if err != nil {
+		return err
+	}
+	return trie.VisitSubtree(key, func(key patricia.Prefix, item patricia.Item) error {
+		tuple, err := Decode(key)
+		if err != nil {
+			return err
+		}
+		return visitor(tuple, item)
+	})
+}
+
+// Internal helper functions ---------------------------------------------------
+
+func appendEscaped(dst []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		if s[i] == terminator {
+			dst = append(dst, terminator, escape)
+		} else {
+			dst = append(dst, s[i])
+		}
+	}
+	return append(dst, terminator)
+}
+
+// decodeEscaped returns the unescaped bytes at the beginning of b
+// and the number of bytes consumed, including the terminator.
+func decodeEscaped(b []byte) ([]byte, int, error) {
+	out := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		if b[i] != terminator {
+			out = append(out, b[i])
+			continue
+		}
+		if i+1 < len(b) && b[i+1] == escape {
+			out = append(out, terminator)
+			i++
+			continue
+		}
+		return out, i + 1, nil
+	}
+	return nil, 0, ErrInvalidKey
+}
+
+func appendUint64(dst []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(dst, buf[:]...)
+}
+
+func decodeUint64(b []byte) (uint64, int, error) {
+	if len(b) < 8 {
+		return 0, 0, ErrInvalidKey
+	}
+	return binary.BigEndian.Uint64(b), 8, nil
+}
+
+// Errors ----------------------------------------------------------------------
+
+var (
+	ErrInvalidKey      = errors.New("Invalid tuple encoding")
+	ErrUnsupportedType = errors.New("Unsupported tuple value type")
+)
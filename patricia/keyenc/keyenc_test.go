@@ -0,0 +1,149 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package keyenc
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tchap/go-patricia/v2/patricia"
+)
+
+// Tests -----------------------------------------------------------------------
+
+func TestEncode_Order(t *testing.T) {
+	base := time.Date(2014, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// Every tuple must be encoded below the next one.
+	tuples := []Tuple{
+		{[]byte{}},
+		{[]byte{0}},
+		{""},
+		{"", int64(0)},
+		{"\x00"},
+		{"\x00\x00"},
+		{"\x00\x01"},
+		{"a"},
+		{"a", "b"},
+		{"a\x00"},
+		{"ab"},
+		{int64(math.MinInt64)},
+		{int64(-2)},
+		{int64(-1)},
+		{int64(0)},
+		{int64(1)},
+		{int64(2), "x"},
+		{int64(10)},
+		{int64(math.MaxInt64)},
+		{uint64(0)},
+		{uint64(math.MaxUint64)},
+		{math.Inf(-1)},
+		{-1.5},
+		{-math.SmallestNonzeroFloat64},
+		{math.Copysign(0, -1)},
+		{0.0},
+		{math.SmallestNonzeroFloat64},
+		{2.0},
+		{10.0},
+		{math.Inf(1)},
+		{time.Unix(-1, 500)},
+		{time.Unix(0, 0)},
+		{base},
+		{base.Add(time.Nanosecond)},
+		{base.Add(time.Second)},
+	}
+
+	var prev patricia.Prefix
+	for i, tuple := range tuples {
+		key, err := Encode(tuple...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i != 0 && bytes.Compare(prev, key) >= 0 {
+			t.Errorf("Order not preserved, %v >= %v", tuples[i-1], tuple)
+		}
+		prev = key
+	}
+}
+
+func TestDecode(t *testing.T) {
+	at := time.Date(1969, 12, 31, 23, 59, 59, 999, time.FixedZone("CET", 3600))
+
+	key, err := Encode("tenant\x00a", []byte("id"), -7, uint8(7), float32(0.5), at)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tuple, err := Decode(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Tuple{"tenant\x00a", []byte("id"), int64(-7), uint64(7), 0.5, at.UTC()}
+	if !reflect.DeepEqual(tuple, expected) {
+		t.Errorf("Unexpected tuple, expected=%v, got=%v", expected, tuple)
+	}
+
+	for _, key := range []string{"\x02abc", "\x03\x00", "\x07", "\x06\x00\x00"} {
+		if _, err := Decode([]byte(key)); err != ErrInvalidKey {
+			t.Errorf("Unexpected error, key=%q, expected=%v, got=%v", key, ErrInvalidKey, err)
+		}
+	}
+}
+
+func TestEncode_UnsupportedType(t *testing.T) {
+	if _, err := Encode("a", true); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Unexpected error, expected=%v, got=%v", ErrUnsupportedType, err)
+	}
+	if key, err := Encode(); key == nil || err != nil {
+		t.Errorf("Unexpected result for the empty tuple, key=%v, err=%v", key, err)
+	}
+}
+
+func TestVisitTuplePrefix(t *testing.T) {
+	trie := patricia.NewTrie()
+
+	base := time.Date(2014, 5, 1, 12, 0, 0, 0, time.UTC)
+	records := []Tuple{
+		{"acme", base.Add(time.Hour), int64(2)},
+		{"acme", base, int64(10)},
+		{"acme", base, int64(9)},
+		{"acme corp", base, int64(1)},
+		{"initech", base, int64(3)},
+	}
+	for i, record := range records {
+		key, err := Encode(record...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trie.Insert(key, i)
+	}
+
+	var items []patricia.Item
+	err := VisitTuplePrefix(trie, Tuple{"acme"}, func(tuple Tuple, item patricia.Item) error {
+		if !reflect.DeepEqual(tuple, records[item.(int)]) {
+			t.Errorf("Unexpected tuple, expected=%v, got=%v", records[item.(int)], tuple)
+		}
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []patricia.Item{2, 1, 0}; !reflect.DeepEqual(items, expected) {
+		t.Errorf("Unexpected items visited, expected=%v, got=%v", expected, items)
+	}
+
+	trie.Insert(patricia.Prefix("\x02acme\x00garbage"), 5)
+	if err := VisitTuplePrefix(trie, Tuple{"acme"}, func(Tuple, patricia.Item) error {
+		return nil
+	}); err != ErrInvalidKey {
+		t.Errorf("Unexpected error, expected=%v, got=%v", ErrInvalidKey, err)
+	}
+}
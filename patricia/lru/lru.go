@@ -0,0 +1,230 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+// Package lru provides a size-bounded LRU cache keyed by prefixes,
+// which makes it possible to invalidate whole subtrees of keys at once.
+package lru
+
+import (
+	"container/list"
+
+	"github.com/tchap/go-patricia/v2/patricia"
+)
+
+//------------------------------------------------------------------------------
+// Cache
+//------------------------------------------------------------------------------
+
+// Cache is an LRU cache built on top of patricia.Trie. The trie maps keys
+// to the elements of a recency list, so that both lookups and recency updates
+// are cheap.
+//
+// Cache is not thread-safe. Synchronize the access yourself.
+type Cache struct {
+	trie    *patricia.Trie
+	recency *list.List
+
+	maxEntries int
+	maxBytes   int64
+	bytes      int64
+	size       SizeFunc
+	onEvict    EvictFunc
+
+	trieOptions []patricia.Option
+}
+
+// SizeFunc returns the number of bytes accounted for the given entry.
+type SizeFunc func(key patricia.Prefix, item patricia.Item) int64
+
+// EvictFunc is called for every entry leaving the cache, except when the item
+// is being replaced by Set. key must not be modified.
+type EvictFunc func(key patricia.Prefix, item patricia.Item)
+
+// entry is the value of every recency list element.
+type entry struct {
+	key  patricia.Prefix
+	item patricia.Item
+	size int64
+}
+
+// Public API ------------------------------------------------------------------
+
+type Option func(*Cache)
+
+// Cache constructor. With no limit set, the cache grows without bounds.
+func New(options ...Option) *Cache {
+	cache := &Cache{
+		recency: list.New(),
+	}
+
+	for _, opt := range options {
+		opt(cache)
+	}
+
+	if cache.size == nil {
+		cache.size = func(key patricia.Prefix, item patricia.Item) int64 {
+			return int64(len(key))
+		}
+	}
+
+	cache.trie = patricia.NewTrie(cache.trieOptions...)
+	return cache
+}
+
+// MaxEntries limits the number of entries in the cache.
+func MaxEntries(value int) Option {
+	return func(cache *Cache) {
+		cache.maxEntries = value
+	}
+}
+
+// MaxBytes limits the total size of the entries in the cache,
+// as computed by the function set using Sizer.
+func MaxBytes(value int64) Option {
+	return func(cache *Cache) {
+		cache.maxBytes = value
+	}
+}
+
+// Sizer sets the function computing entry sizes for MaxBytes.
+// By default the size of an entry is the length of its key.
+func Sizer(size SizeFunc) Option {
+	return func(cache *Cache) {
+		cache.size = size
+	}
+}
+
+// OnEvict sets the function to be called for every entry leaving the cache.
+func OnEvict(onEvict EvictFunc) Option {
+	return func(cache *Cache) {
+		cache.onEvict = onEvict
+	}
+}
+
+// TrieOptions sets the options passed to patricia.NewTrie.
+func TrieOptions(options ...patricia.Option) Option {
+	return func(cache *Cache) {
+		cache.trieOptions = options
+	}
+}
+
+// Set stores item under key, marking it as the most recently used entry.
+// Least recently used entries are evicted as necessary to fit into the limits.
+func (cache *Cache) Set(key patricia.Prefix, item patricia.Item) {
+	// Storing nil is the same as deleting, as usual.
+	if item == nil {
+		cache.Delete(key)
+		return
+	}
+
+	cache.trie.Update(key, func(old patricia.Item, exists bool) (patricia.Item, bool) {
+		if exists {
+			element := old.(*list.Element)
+			e := element.Value.(*entry)
+			cache.bytes -= e.size
+			e.item = item
+			e.size = cache.size(e.key, item)
+			cache.bytes += e.size
+			cache.recency.MoveToFront(element)
+			return element, true
+		}
+
+		e := &entry{
+			key:  append(make(patricia.Prefix, 0, len(key)), key...),
+			item: item,
+		}
+		e.size = cache.size(e.key, item)
+		cache.bytes += e.size
+		return cache.recency.PushFront(e), true
+	})
+
+	cache.shrink()
+}
+
+// Get returns the item stored under key, marking it as the most recently used.
+func (cache *Cache) Get(key patricia.Prefix) (item patricia.Item, ok bool) {
+	element, ok := cache.trie.Get(key).(*list.Element)
+	if !ok {
+		return nil, false
+	}
+	cache.recency.MoveToFront(element)
+	return element.Value.(*entry).item, true
+}
+
+// Peek works like Get, but it does not affect the recency of the entry.
+func (cache *Cache) Peek(key patricia.Prefix) (item patricia.Item, ok bool) {
+	element, ok := cache.trie.Get(key).(*list.Element)
+	if !ok {
+		return nil, false
+	}
+	return element.Value.(*entry).item, true
+}
+
+// Delete deletes the entry stored under key.
+//
+// True is returned if the entry was found and deleted.
+func (cache *Cache) Delete(key patricia.Prefix) (deleted bool) {
+	item, ok := cache.trie.Remove(key)
+	if ok {
+		cache.drop(item.(*list.Element))
+	}
+	return ok
+}
+
+// InvalidatePrefix deletes all entries with keys starting with prefix,
+// removing the whole subtree from the trie at once.
+// The number of entries deleted is returned.
+func (cache *Cache) InvalidatePrefix(prefix patricia.Prefix) (removed int) {
+	return cache.trie.RemoveSubtree(prefix, func(key patricia.Prefix, item patricia.Item) {
+		cache.drop(item.(*list.Element))
+	})
+}
+
+// Len returns the number of entries in the cache.
+func (cache *Cache) Len() int {
+	return cache.recency.Len()
+}
+
+// Bytes returns the total size of the entries in the cache.
+func (cache *Cache) Bytes() int64 {
+	return cache.bytes
+}
+
+// VisitSubtree calls visitor on all entries with keys starting with prefix
+// in alphabetical order, without affecting their recency.
+// See patricia.Trie.VisitSubtree for more details.
+func (cache *Cache) VisitSubtree(prefix patricia.Prefix, visitor patricia.VisitorFunc) error {
+	return cache.trie.VisitSubtree(prefix, func(key patricia.Prefix, item patricia.Item) error {
+		return visitor(key, item.(*list.Element).Value.(*entry).item)
+	})
+}
+
+// Internal helper methods -----------------------------------------------------
+
+// shrink evicts the least recently used entries until the limits are met.
+func (cache *Cache) shrink() {
+	for cache.overLimit() {
+		element := cache.recency.Back()
+		cache.trie.Delete(element.Value.(*entry).key)
+		cache.drop(element)
+	}
+}
+
+func (cache *Cache) overLimit() bool {
+	if cache.recency.Len() == 0 {
+		return false
+	}
+	return cache.maxEntries > 0 && cache.recency.Len() > cache.maxEntries ||
+		cache.maxBytes > 0 && cache.bytes > cache.maxBytes
+}
+
+// drop removes the element, which is already removed from the trie.
+func (cache *Cache) drop(element *list.Element) {
+	e := cache.recency.Remove(element).(*entry)
+	cache.bytes -= e.size
+	if cache.onEvict != nil {
+		cache.onEvict(e.key, e.item)
+	}
+}
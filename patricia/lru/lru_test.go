@@ -0,0 +1,157 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package lru
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tchap/go-patricia/v2/patricia"
+)
+
+// Tests -----------------------------------------------------------------------
+
+func TestCache_MaxEntries(t *testing.T) {
+	var evicted []string
+	cache := New(MaxEntries(2), OnEvict(func(key patricia.Prefix, item patricia.Item) {
+		evicted = append(evicted, string(key))
+	}))
+
+	cache.Set(patricia.Prefix("Pepa"), 1)
+	cache.Set(patricia.Prefix("Honza"), 2)
+
+	// Make Pepa the most recently used entry.
+	if item, ok := cache.Get(patricia.Prefix("Pepa")); !ok || item != 1 {
+		t.Errorf("Unexpected return value, expected=1 true, got=%v %v", item, ok)
+	}
+
+	cache.Set(patricia.Prefix("Jenik"), 3)
+
+	if expected := []string{"Honza"}; !reflect.DeepEqual(evicted, expected) {
+		t.Errorf("Unexpected entries evicted, expected=%q, got=%q", expected, evicted)
+	}
+	if _, ok := cache.Get(patricia.Prefix("Honza")); ok {
+		t.Error("Evicted entry still present")
+	}
+	if n := cache.Len(); n != 2 {
+		t.Errorf("Unexpected length, expected=2, got=%v", n)
+	}
+}
+
+func TestCache_MaxBytes(t *testing.T) {
+	cache := New(MaxBytes(10), Sizer(func(key patricia.Prefix, item patricia.Item) int64 {
+		return int64(item.(int))
+	}))
+
+	cache.Set(patricia.Prefix("a"), 4)
+	cache.Set(patricia.Prefix("b"), 4)
+	if n := cache.Bytes(); n != 8 {
+		t.Errorf("Unexpected size, expected=8, got=%v", n)
+	}
+
+	// Growing an entry counts as well.
+	cache.Set(patricia.Prefix("b"), 6)
+	if n := cache.Bytes(); n != 10 {
+		t.Errorf("Unexpected size, expected=10, got=%v", n)
+	}
+
+	cache.Set(patricia.Prefix("c"), 5)
+	if _, ok := cache.Peek(patricia.Prefix("a")); ok {
+		t.Error("Least recently used entry not evicted")
+	}
+	if _, ok := cache.Peek(patricia.Prefix("b")); ok {
+		t.Error("Second least recently used entry not evicted")
+	}
+	if n := cache.Bytes(); n != 5 {
+		t.Errorf("Unexpected size, expected=5, got=%v", n)
+	}
+}
+
+func TestCache_Peek(t *testing.T) {
+	cache := New(MaxEntries(2))
+
+	cache.Set(patricia.Prefix("Pepa"), 1)
+	cache.Set(patricia.Prefix("Honza"), 2)
+	cache.Peek(patricia.Prefix("Pepa"))
+	cache.Set(patricia.Prefix("Jenik"), 3)
+
+	if _, ok := cache.Peek(patricia.Prefix("Pepa")); ok {
+		t.Error("Peek affected the recency of the entry")
+	}
+}
+
+func TestCache_InvalidatePrefix(t *testing.T) {
+	var evicted []string
+	cache := New(OnEvict(func(key patricia.Prefix, item patricia.Item) {
+		evicted = append(evicted, string(key))
+	}))
+
+	cache.Set(patricia.Prefix("user:42:name"), "Pepa")
+	cache.Set(patricia.Prefix("user:42:mail"), "pepa@example.com")
+	cache.Set(patricia.Prefix("user:43:name"), "Honza")
+	cache.Set(patricia.Prefix("user:4"), "?")
+
+	if removed := cache.InvalidatePrefix(patricia.Prefix("user:42:")); removed != 2 {
+		t.Errorf("Unexpected number of entries invalidated, expected=2, got=%v", removed)
+	}
+
+	expected := []string{"user:42:mail", "user:42:name"}
+	if !reflect.DeepEqual(evicted, expected) {
+		t.Errorf("Unexpected entries evicted, expected=%q, got=%q", expected, evicted)
+	}
+	if n := cache.Len(); n != 2 {
+		t.Errorf("Unexpected length, expected=2, got=%v", n)
+	}
+	if n := cache.Bytes(); n != int64(len("user:43:name")+len("user:4")) {
+		t.Errorf("Unexpected size: %v", n)
+	}
+
+	var visited []string
+	cache.VisitSubtree(patricia.Prefix("user:"), func(key patricia.Prefix, item patricia.Item) error {
+		visited = append(visited, string(key))
+		return nil
+	})
+	if expected := []string{"user:4", "user:43:name"}; !reflect.DeepEqual(visited, expected) {
+		t.Errorf("Unexpected keys visited, expected=%q, got=%q", expected, visited)
+	}
+
+	// The recency list must be consistent with the trie, fill it up again.
+	cache.maxEntries = 1
+	cache.Set(patricia.Prefix("user:44:name"), "Jenik")
+	if n := cache.Len(); n != 1 {
+		t.Errorf("Unexpected length, expected=1, got=%v", n)
+	}
+}
+
+func TestCache_Delete(t *testing.T) {
+	cache := New()
+
+	cache.Set(patricia.Prefix("Pepa"), 1)
+	if ok := cache.Delete(patricia.Prefix("Pepa")); !ok {
+		t.Error("Delete failed")
+	}
+	if ok := cache.Delete(patricia.Prefix("Pepa")); ok {
+		t.Error("Extra delete succeeded")
+	}
+	if n := cache.Len(); n != 0 {
+		t.Errorf("Unexpected length, expected=0, got=%v", n)
+	}
+}
+
+func TestCache_EmptyKey(t *testing.T) {
+	cache := New(MaxEntries(1))
+
+	// Evicting the empty key must not pass a nil key to the trie.
+	cache.Set(patricia.Prefix(""), 1)
+	cache.Set(patricia.Prefix("Pepa"), 2)
+
+	if _, ok := cache.Get(patricia.Prefix("")); ok {
+		t.Error("Evicted entry still present")
+	}
+	if item, ok := cache.Get(patricia.Prefix("Pepa")); !ok || item != 2 {
+		t.Errorf("Unexpected return value, expected=2 true, got=%v %v", item, ok)
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+import "io"
+
+//------------------------------------------------------------------------------
+// Multi-pattern matching
+//------------------------------------------------------------------------------
+
+// MatchFunc is called for every occurrence of a key in the text being scanned,
+// offset being the position in the text where the key starts.
+//
+// key must not be modified.
+type MatchFunc func(offset int, key Prefix, item Item) error
+
+// Matcher finds all occurrences of a set of keys in a text in a single pass,
+// implementing the Aho-Corasick algorithm. It is created using CompileMatcher.
+//
+// Matcher is a snapshot, it is not affected by modifications of the trie
+// it was compiled from. It is safe for concurrent use.
+type Matcher struct {
+	states []matcherState
+	// root is the transition table of the root state, kept dense
+	// since the root is the state the automaton falls back to all the time.
+	root [256]int32
+	// foldCase makes the automaton fold the text as CaseInsensitive does.
+	foldCase bool
+}
+
+// matcherState is a single byte of a key. Unlike in the trie, the edges are
+// labeled with a single byte each.
+type matcherState struct {
+	edges []matcherEdge
+	// fail is the state representing the longest proper suffix
+	// of this state that is present in the automaton.
+	fail int32
+	// output is the next state on the failure chain with an item set,
+	// 0 when there is none.
+	output int32
+
+	key  Prefix
+	item Item
+}
+
+type matcherEdge struct {
+	b     byte
+	state int32
+}
+
+// Public API ------------------------------------------------------------------
+
+// CompileMatcher builds a Matcher reporting all the keys stored in the trie.
+// The empty key is never reported. When the trie is CaseInsensitive,
+// so is the matcher, reporting the keys as inserted.
+func (trie *Trie) CompileMatcher() *Matcher {
+	m := &Matcher{
+		states:   []matcherState{{}},
+		foldCase: trie.caseInsensitive,
+	}
+	for i := range m.root {
+		m.root[i] = -1
+	}
+
+	trie.Visit(func(key Prefix, item Item) error {
+		if len(key) != 0 {
+			m.add(trie.foldKey(key), key, item)
+		}
+		return nil
+	})
+	m.link()
+	return m
+}
+
+// Scan calls fn for every occurrence of a key in text, in the order of their
+// end offsets, longer keys first. Occurrences may overlap.
+//
+// If an error is returned from fn, Scan stops and returns that error.
+func (m *Matcher) Scan(text []byte, fn MatchFunc) error {
+	var state int32
+	for i, b := range text {
+		state = m.step(state, b)
+		if err := m.emit(state, i+1, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScanReader works like Scan, but it reads the text from r until io.EOF.
+// Errors returned by r other than io.EOF are returned as well.
+func (m *Matcher) ScanReader(r io.Reader, fn MatchFunc) error {
+	var (
+		state int32
+		pos   int
+		buf   = make([]byte, 32*1024)
+	)
+	for {
+		n, err := r.Read(buf)
+		for _, b := range buf[:n] {
+			pos++
+			state = m.step(state, b)
+			if err := m.emit(state, pos, fn); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Internal helper methods -----------------------------------------------------
+
+// add inserts path into the goto function of the automaton,
+// key being reported for it.
+func (m *Matcher) add(path, key Prefix, item Item) {
+	var state int32
+	for _, b := range path {
+		next := m.child(state, b)
+		if next < 0 {
+			next = int32(len(m.states))
+			m.states = append(m.states, matcherState{})
+			if state == 0 {
+				m.root[b] = next
+			} else {
+				m.states[state].edges = append(m.states[state].edges, matcherEdge{b, next})
+			}
+		}
+		state = next
+	}
+	m.states[state].key = append(make(Prefix, 0, len(key)), key...)
+	m.states[state].item = item
+}
+
+// link computes the failure and output links, breadth-first.
+func (m *Matcher) link() {
+	queue := make([]int32, 0, len(m.states))
+	for _, child := range m.root {
+		if child > 0 {
+			queue = append(queue, child)
+		}
+	}
+
+	for len(queue) != 0 {
+		parent := queue[0]
+		queue = queue[1:]
+
+		for _, edge := range m.states[parent].edges {
+			child := &m.states[edge.state]
+			child.fail = m.step(m.states[parent].fail, edge.b)
+
+			fail := &m.states[child.fail]
+			if fail.item != nil {
+				child.output = child.fail
+			} else {
+				child.output = fail.output
+			}
+			queue = append(queue, edge.state)
+		}
+	}
+}
+
+// child returns the state reached from state over b, -1 if there is none.
+func (m *Matcher) child(state int32, b byte) int32 {
+	if state == 0 {
+		return m.root[b]
+	}
+	for _, edge := range m.states[state].edges {
+		if edge.b == b {
+			return edge.state
+		}
+	}
+	return -1
+}
+
+// step returns the state the automaton moves to from state when reading b.
+func (m *Matcher) step(state int32, b byte) int32 {
+	if m.foldCase {
+		b = foldByte(b)
+	}
+	for {
+		if next := m.child(state, b); next >= 0 {
+			return next
+		}
+		if state == 0 {
+			return 0
+		}
+		state = m.states[state].fail
+	}
+}
+
+// emit reports all the keys ending at end, the automaton being in state.
+func (m *Matcher) emit(state int32, end int, fn MatchFunc) error {
+	if m.states[state].item == nil {
+		state = m.states[state].output
+	}
+	for state != 0 {
+		s := &m.states[state]
+		if err := fn(end-len(s.key), s.key, s.item); err != nil {
+			return err
+		}
+		state = s.output
+	}
+	return nil
+}
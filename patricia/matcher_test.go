@@ -0,0 +1,156 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// Tests -----------------------------------------------------------------------
+
+func TestMatcher_Scan(t *testing.T) {
+	trie := trieOf(map[string]Item{"he": 1, "she": 2, "his": 3, "hers": 4, "": 5})
+	m := trie.CompileMatcher()
+
+	var matches []string
+	err := m.Scan([]byte("ushers"), func(offset int, key Prefix, item Item) error {
+		matches = append(matches, fmt.Sprintf("%v %s %v", offset, key, item))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"1 she 2", "2 he 1", "2 hers 4"}
+	if !reflect.DeepEqual(matches, expected) {
+		t.Errorf("Unexpected matches, expected=%q, got=%q", expected, matches)
+	}
+
+	// The matcher is a snapshot.
+	trie.Delete(Prefix("she"))
+	matches = nil
+	m.Scan([]byte("she"), func(offset int, key Prefix, item Item) error {
+		matches = append(matches, string(key))
+		return nil
+	})
+	if expected := []string{"she", "he"}; !reflect.DeepEqual(matches, expected) {
+		t.Errorf("Unexpected matches, expected=%q, got=%q", expected, matches)
+	}
+}
+
+func TestMatcher_ScanReturnError(t *testing.T) {
+	m := trieOf(map[string]Item{"a": 1}).CompileMatcher()
+
+	someErr := errors.New("Something exploded")
+	calls := 0
+	if err := m.Scan([]byte("aaa"), func(int, Prefix, Item) error {
+		calls++
+		return someErr
+	}); err != someErr {
+		t.Errorf("Unexpected error, expected=%v, got=%v", someErr, err)
+	}
+	if calls != 1 {
+		t.Errorf("Matcher called %v times after returning an error", calls)
+	}
+}
+
+func TestMatcher_Random(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	randomText := func(n int) []byte {
+		text := make([]byte, n)
+		for i := range text {
+			text[i] = "abc"[rnd.Intn(3)]
+		}
+		return text
+	}
+
+	items := make(map[string]Item)
+	for i := 0; i < 100; i++ {
+		items[string(randomText(1+rnd.Intn(6)))] = i
+	}
+	trie := trieOf(items, MaxPrefixPerNode(2))
+	m := trie.CompileMatcher()
+
+	text := randomText(5000)
+
+	// Compare with VisitPrefixes called at every offset.
+	var expected []string
+	for i := range text {
+		trie.VisitPrefixes(Prefix(text[i:]), func(prefix Prefix, item Item) error {
+			expected = append(expected, fmt.Sprintf("%v %s %v", i, prefix, item))
+			return nil
+		})
+	}
+	sort.Strings(expected)
+
+	collect := func(matches *[]string) MatchFunc {
+		return func(offset int, key Prefix, item Item) error {
+			if !bytes.Equal(text[offset:offset+len(key)], key) {
+				t.Fatalf("Key not found at the offset reported: %v %q", offset, key)
+			}
+			*matches = append(*matches, fmt.Sprintf("%v %s %v", offset, key, item))
+			return nil
+		}
+	}
+
+	var matches []string
+	m.Scan(text, collect(&matches))
+	sort.Strings(matches)
+	if !reflect.DeepEqual(matches, expected) {
+		t.Errorf("Unexpected matches, expected %v, got %v", len(expected), len(matches))
+	}
+
+	matches = nil
+	if err := m.ScanReader(iotest.OneByteReader(bytes.NewReader(text)), collect(&matches)); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(matches)
+	if !reflect.DeepEqual(matches, expected) {
+		t.Errorf("Unexpected matches from reader, expected %v, got %v", len(expected), len(matches))
+	}
+}
+
+func TestMatcher_ScanReaderError(t *testing.T) {
+	m := trieOf(map[string]Item{"a": 1}).CompileMatcher()
+
+	r := iotest.TimeoutReader(iotest.OneByteReader(strings.NewReader("aaa")))
+	if err := m.ScanReader(r, func(int, Prefix, Item) error { return nil }); err != iotest.ErrTimeout {
+		t.Errorf("Unexpected error, expected=%v, got=%v", iotest.ErrTimeout, err)
+	}
+}
+
+// Benchmarks ------------------------------------------------------------------
+
+func BenchmarkMatcher_Scan(b *testing.B) {
+	trie := NewTrie()
+	for i := 0; i < 5000; i++ {
+		key := make([]byte, 4+rand.Intn(8))
+		for j := range key {
+			key[j] = byte('a' + rand.Intn(26))
+		}
+		trie.Set(key, i)
+	}
+	m := trie.CompileMatcher()
+
+	text := make([]byte, 64*1024)
+	for i := range text {
+		text[i] = byte('a' + rand.Intn(26))
+	}
+
+	b.SetBytes(int64(len(text)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Scan(text, func(int, Prefix, Item) error { return nil })
+	}
+}
@@ -0,0 +1,313 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+import "reflect"
+
+//------------------------------------------------------------------------------
+// Set operations
+//------------------------------------------------------------------------------
+
+// MergeFunc decides what item to keep for key when it is present in both tries,
+// a being the item from the trie being modified. Returning nil removes the key.
+//
+// key is only valid until the function returns.
+type MergeFunc func(key Prefix, a, b Item) Item
+
+// Merge turns trie into the union of trie and other.
+//
+// Keys present in both tries are passed to resolve, which can be nil, in which
+// case the item from other wins. Subtrees present only in other are copied,
+// the items themselves become shared. With CaseInsensitive, the original key
+// is taken from the trie the item resolved comes from.
+func (trie *Trie) Merge(other *Trie, resolve MergeFunc) {
+	if resolve == nil {
+		resolve = func(key Prefix, a, b Item) Item {
+			return b
+		}
+	}
+	trie.combine(other, &setOp{
+		keepA:    true,
+		keepB:    true,
+		keepBoth: true,
+		resolve:  resolve,
+	})
+}
+
+// Intersect removes all keys not present in other from trie.
+//
+// Keys present in both tries are passed to resolve, which can be nil,
+// in which case the item from trie is kept.
+func (trie *Trie) Intersect(other *Trie, resolve MergeFunc) {
+	if resolve == nil {
+		resolve = func(key Prefix, a, b Item) Item {
+			return a
+		}
+	}
+	trie.combine(other, &setOp{
+		keepBoth: true,
+		resolve:  resolve,
+	})
+}
+
+// Difference removes all keys present in other from trie.
+func (trie *Trie) Difference(other *Trie) {
+	trie.combine(other, &setOp{
+		keepA: true,
+	})
+}
+
+// SymmetricDifference turns trie into the set of keys that are present
+// in exactly one of trie and other.
+func (trie *Trie) SymmetricDifference(other *Trie) {
+	trie.combine(other, &setOp{
+		keepA: true,
+		keepB: true,
+	})
+}
+
+// Internal helper methods -----------------------------------------------------
+
+// setOp recurses over both tries at once, modifying the first one in place.
+// Whenever the tries fork in the middle of a node prefix, the node in the trie
+// being modified is split so that both tries can be descended in lockstep.
+type setOp struct {
+	// keepA keeps the items present only in the trie being modified.
+	keepA bool
+	// keepB copies the items present only in the other trie.
+	keepB bool
+	// keepBoth keeps the keys present in both, passing them to resolve.
+	keepBoth bool
+	resolve  MergeFunc
+
+	root *Trie
+	key  Prefix
+}
+
+func (trie *Trie) combine(other *Trie, op *setOp) {
+	// Merging a trie with itself must not see its own modifications.
+	if other == trie {
+		other = other.Clone()
+	}
+	op.root = trie
+
+	if trie.reverseKeys && op.resolve != nil {
+		var buf Prefix
+		resolve := op.resolve
+		op.resolve = func(key Prefix, a, b Item) Item {
+			buf = trie.decodeKey(buf, key)
+			return resolve(buf, a, b)
+		}
+	}
+
+	switch {
+	case other.prefix == nil:
+		if !op.keepA {
+			trie.unlinked()
+			trie.item = nil
+			trie.reset()
+		}
+
+	case trie.prefix == nil:
+		if op.keepB {
+			op.build(trie, other, 0)
+		}
+
+	default:
+		switch merged := op.merge(trie, other, 0); {
+		case merged == nil:
+			trie.unlinked()
+			trie.item = nil
+			trie.reset()
+		case merged != trie:
+			*trie = *merged
+		}
+	}
+}
+
+// merge combines the subtree rooted at a with the subtree rooted at b, the first
+// ib bytes of b.prefix being matched already. The node to replace a with
+// is returned, nil when the subtree ends up empty.
+func (op *setOp) merge(a, b *Trie, ib int) *Trie {
+	rb := b.prefix[ib:]
+	common := a.longestCommonPrefixLength(rb)
+
+	// The tries fork in the middle of both prefixes.
+	if common < len(a.prefix) && common < len(rb) {
+		switch {
+		case !op.keepB && !op.keepA:
+			return nil
+		case !op.keepB:
+			return a
+		case !op.keepA:
+			a.unlinked()
+			return op.graft(b, ib)
+		}
+		a.split(common)
+		a.children = a.children.add(op.graft(b, ib+common))
+		a.aggregate(append(op.key[:len(op.key):len(op.key)], a.prefix...))
+		return a
+	}
+
+	// Make the prefixes match exactly in case b ends in the middle of a.
+	if common < len(a.prefix) {
+		a.split(common)
+	}
+
+	base := len(op.key)
+	op.key = append(op.key, a.prefix...)
+	defer func() {
+		op.key = op.key[:base]
+	}()
+
+	// b continues in the middle of its prefix.
+	if common < len(rb) {
+		b0 := rb[common]
+		if !op.keepA {
+			a.item = nil
+			a.original = nil
+			for _, child := range a.children.sorted() {
+				if child.prefix[0] != b0 {
+					child.unlinked()
+					a.children.remove(child.prefix[0])
+				}
+			}
+		}
+
+		if child := a.children.next(b0); child != nil {
+			op.mergeChild(a, child, b, ib+common)
+		} else if op.keepB {
+			a.children = a.children.add(op.graft(b, ib+common))
+		}
+		return op.tidy(a)
+	}
+
+	// Both a and b end at the same key.
+	switch {
+	case a.item != nil && b.item != nil:
+		if op.keepBoth {
+			item := op.resolve(a.visitKey(op.key), a.item, b.item)
+			// The original key goes along with the item kept.
+			if !sameItem(item, a.item) && sameItem(item, b.item) {
+				a.original = op.root.storeOriginal(b.original)
+			}
+			a.item = item
+		} else {
+			a.item = nil
+		}
+	case a.item != nil:
+		if !op.keepA {
+			a.item = nil
+		}
+	case b.item != nil:
+		if op.keepB {
+			a.item = b.item
+			a.original = op.root.storeOriginal(b.original)
+		}
+	}
+	if a.item == nil {
+		a.original = nil
+	}
+
+	if !op.keepA {
+		for _, child := range a.children.sorted() {
+			if b.children.next(child.prefix[0]) == nil {
+				child.unlinked()
+				a.children.remove(child.prefix[0])
+			}
+		}
+	}
+	bChildren := b.children.sorted()
+	orderTries(bChildren, op.key, op.root.keyOrder)
+	for _, bChild := range bChildren {
+		if child := a.children.next(bChild.prefix[0]); child != nil {
+			op.mergeChild(a, child, bChild, 0)
+		} else if op.keepB {
+			a.children = a.children.add(op.graft(bChild, 0))
+		}
+	}
+	return op.tidy(a)
+}
+
+func (op *setOp) mergeChild(a, child, b *Trie, ib int) {
+	b0 := child.prefix[0]
+	switch merged := op.merge(child, b, ib); {
+	case merged == nil:
+		child.unlinked()
+		a.children.remove(b0)
+	case merged != child:
+		a.children.replace(b0, merged)
+	}
+}
+
+// tidy drops the node, which represents op.key, when empty,
+// otherwise it tries to compact it.
+func (op *setOp) tidy(node *Trie) *Trie {
+	if node.empty() {
+		return nil
+	}
+	node.aggregate(op.key)
+	return node.compact()
+}
+
+// graft copies the subtree rooted at b, minus the first ib bytes of b.prefix,
+// so that it can be linked into the trie being modified.
+func (op *setOp) graft(b *Trie, ib int) *Trie {
+	root := op.root.newNode()
+	op.build(root, b, ib)
+	return root.children.head()
+}
+
+// build fills the empty root with the subtree rooted at b,
+// minus the first ib bytes of b.prefix.
+func (op *setOp) build(root *Trie, b *Trie, ib int) {
+	builder := newBuilder(root)
+	builder.copyKeys = true
+	op.copy(builder, b, append(Prefix{}, b.prefix[ib:]...))
+	builder.close()
+}
+
+// copy adds the items in the subtree rooted at node, which represents key,
+// to builder. Unlike walk, it always passes on the keys the nodes represent.
+func (op *setOp) copy(builder *builder, node *Trie, key Prefix) {
+	if node.item != nil {
+		// The keys come from a trie, so they are sorted and unique.
+		if err := builder.add(key, node.original, node.item); err != nil {
+			panic(err)
+		}
+	}
+	for _, child := range node.children.sorted() {
+		op.copy(builder, child, append(key, child.prefix...))
+	}
+}
+
+// sameItem reports whether x and y are the same item. Slices, maps and funcs
+// are compared by identity, other items that cannot be compared using ==
+// are never the same.
+func sameItem(x, y Item) (same bool) {
+	tx, ty := reflect.TypeOf(x), reflect.TypeOf(y)
+	switch {
+	case tx != ty:
+		return false
+	case tx == nil:
+		return true
+	case tx.Comparable():
+		// Comparing structs holding uncomparable values in interfaces panics.
+		defer func() {
+			recover()
+		}()
+		return x == y
+	}
+
+	vx, vy := reflect.ValueOf(x), reflect.ValueOf(y)
+	switch tx.Kind() {
+	case reflect.Slice:
+		return vx.Pointer() == vy.Pointer() && vx.Len() == vy.Len()
+	case reflect.Map, reflect.Func:
+		return vx.Pointer() == vy.Pointer()
+	}
+	return false
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// Tests -----------------------------------------------------------------------
+
+func TestTrie_Merge(t *testing.T) {
+	trie := trieOf(map[string]Item{"Pepa": 1, "Pepa Zdepa": 2, "Honza": 3})
+	other := trieOf(map[string]Item{"Pepa": 10, "Pepa Kuchar": 20, "Jenik": 30, "Hon": 40})
+
+	trie.Merge(other, func(key Prefix, a, b Item) Item {
+		if string(key) != "Pepa" {
+			t.Errorf("Unexpected key resolved: %q", key)
+		}
+		return a.(int) + b.(int)
+	})
+
+	expected := map[string]Item{
+		"Hon":         40,
+		"Honza":       3,
+		"Jenik":       30,
+		"Pepa":        11,
+		"Pepa Kuchar": 20,
+		"Pepa Zdepa":  2,
+	}
+	if items := itemsOf(trie); !reflect.DeepEqual(items, expected) {
+		t.Errorf("Unexpected items, expected=%v, got=%v", expected, items)
+	}
+
+	// The other trie must stay untouched.
+	if n := other.size(); n != 4 {
+		t.Errorf("Other trie modified, size=%v", n)
+	}
+}
+
+func TestTrie_Intersect(t *testing.T) {
+	trie := trieOf(map[string]Item{"Pepa": 1, "Pepa Zdepa": 2, "Honza": 3, "Jenik": 4})
+	other := trieOf(map[string]Item{"Pepa": 10, "Pepa Kuchar": 20, "Jenik": 30, "Hon": 40})
+
+	trie.Intersect(other, nil)
+
+	expected := map[string]Item{"Pepa": 1, "Jenik": 4}
+	if items := itemsOf(trie); !reflect.DeepEqual(items, expected) {
+		t.Errorf("Unexpected items, expected=%v, got=%v", expected, items)
+	}
+}
+
+func TestTrie_Difference(t *testing.T) {
+	trie := trieOf(map[string]Item{"Pepa": 1, "Pepa Zdepa": 2, "Honza": 3, "Jenik": 4})
+	other := trieOf(map[string]Item{"Pepa": 10, "Pepa Kuchar": 20, "Jenik": 30, "Hon": 40})
+
+	trie.Difference(other)
+
+	expected := map[string]Item{"Pepa Zdepa": 2, "Honza": 3}
+	if items := itemsOf(trie); !reflect.DeepEqual(items, expected) {
+		t.Errorf("Unexpected items, expected=%v, got=%v", expected, items)
+	}
+}
+
+func TestTrie_SymmetricDifference(t *testing.T) {
+	trie := trieOf(map[string]Item{"Pepa": 1, "Pepa Zdepa": 2, "Honza": 3, "Jenik": 4})
+	other := trieOf(map[string]Item{"Pepa": 10, "Pepa Kuchar": 20, "Jenik": 30, "Hon": 40})
+
+	trie.SymmetricDifference(other)
+
+	expected := map[string]Item{"Pepa Zdepa": 2, "Pepa Kuchar": 20, "Honza": 3, "Hon": 40}
+	if items := itemsOf(trie); !reflect.DeepEqual(items, expected) {
+		t.Errorf("Unexpected items, expected=%v, got=%v", expected, items)
+	}
+}
+
+func TestTrie_MergeEmptyKey(t *testing.T) {
+	other := trieOf(map[string]Item{"": 1, "Pepa": 2})
+
+	trie := NewTrie()
+	trie.Merge(other, nil)
+
+	expected := map[string]Item{"": 1, "Pepa": 2}
+	if items := itemsOf(trie); !reflect.DeepEqual(items, expected) {
+		t.Errorf("Unexpected items, expected=%v, got=%v", expected, items)
+	}
+}
+
+func TestTrie_SetOperationsWithSelf(t *testing.T) {
+	trie := trieOf(map[string]Item{"Pepa": 1, "Honza": 2})
+
+	trie.Merge(trie, nil)
+	if n := trie.size(); n != 2 {
+		t.Errorf("Unexpected size after Merge with self: %v", n)
+	}
+
+	trie.Difference(trie)
+	if !trie.empty() {
+		t.Errorf("Trie not empty after Difference with self:\n%s", trie.dump())
+	}
+}
+
+func TestTrie_SetOperationsRandom(t *testing.T) {
+	ops := []struct {
+		name  string
+		apply func(a, b *Trie)
+		keep  func(inA, inB bool) bool
+	}{
+		{"Merge", func(a, b *Trie) { a.Merge(b, nil) }, func(inA, inB bool) bool { return inA || inB }},
+		{"Intersect", func(a, b *Trie) { a.Intersect(b, nil) }, func(inA, inB bool) bool { return inA && inB }},
+		{"Difference", func(a, b *Trie) { a.Difference(b) }, func(inA, inB bool) bool { return inA && !inB }},
+		{"SymmetricDifference", func(a, b *Trie) { a.SymmetricDifference(b) }, func(inA, inB bool) bool { return inA != inB }},
+	}
+
+	rnd := rand.New(rand.NewSource(42))
+	randomKeys := func() map[string]Item {
+		keys := make(map[string]Item)
+		for i := 0; i < 300; i++ {
+			key := make([]byte, 1+rnd.Intn(12))
+			for j := range key {
+				key[j] = "abc"[rnd.Intn(3)]
+			}
+			keys[string(key)] = i
+		}
+		return keys
+	}
+
+	for round := 0; round < 20; round++ {
+		a, b := randomKeys(), randomKeys()
+		for _, op := range ops {
+			for _, options := range [][]Option{nil, {MaxPrefixPerNode(3), MaxChildrenPerSparseNode(2)}} {
+				trie, other := trieOf(a, options...), trieOf(b, options...)
+				op.apply(trie, other)
+
+				expected := make(map[string]Item)
+				for key, item := range a {
+					if _, inB := b[key]; op.keep(true, inB) {
+						expected[key] = item
+					}
+				}
+				for key, item := range b {
+					if _, inA := a[key]; !inA && op.keep(false, true) {
+						expected[key] = item
+					}
+				}
+				if op.name == "Merge" {
+					// The item from the other trie wins by default.
+					for key := range a {
+						if item, ok := b[key]; ok {
+							expected[key] = item
+						}
+					}
+				}
+
+				if items := itemsOf(trie); !reflect.DeepEqual(items, expected) {
+					t.Fatalf("%v: unexpected items, expected=%v, got=%v", op.name, expected, items)
+				}
+				if items := itemsOf(other); !reflect.DeepEqual(items, b) {
+					t.Fatalf("%v: other trie modified", op.name)
+				}
+				checkMaxPrefixPerNode(t, trie)
+				checkNoEmptyLeaves(t, trie)
+			}
+		}
+	}
+}
+
+// Helpers ---------------------------------------------------------------------
+
+func trieOf(items map[string]Item, options ...Option) *Trie {
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	// Insert in random order to get various tree shapes.
+	rand.Shuffle(len(keys), func(i, j int) {
+		keys[i], keys[j] = keys[j], keys[i]
+	})
+
+	trie := NewTrie(options...)
+	for _, key := range keys {
+		trie.Insert(Prefix(key), items[key])
+	}
+	return trie
+}
+
+func itemsOf(trie *Trie) map[string]Item {
+	items := make(map[string]Item)
+	var prev string
+	trie.Visit(func(prefix Prefix, item Item) error {
+		if len(items) != 0 && string(prefix) <= prev {
+			panic(fmt.Sprintf("keys visited out of order: %q, %q", prev, prefix))
+		}
+		prev = string(prefix)
+		items[prev] = item
+		return nil
+	})
+	return items
+}
+
+func checkNoEmptyLeaves(t *testing.T, trie *Trie) {
+	var check func(node *Trie)
+	check = func(node *Trie) {
+		children := node.children.sorted()
+		if node != trie && node.item == nil && len(children) == 0 {
+			t.Errorf("Empty leaf node found: %q", node.prefix)
+		}
+		for _, child := range children {
+			check(child)
+		}
+	}
+	check(trie)
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+//------------------------------------------------------------------------------
+// Multi-value trie
+//------------------------------------------------------------------------------
+
+// MultiTrie stores any number of items per key, in the order they were added.
+// The same item can be added under the same key multiple times.
+//
+// Items are compared using ==, so they must be of comparable types.
+// Nil items are not stored, same as in Trie.
+//
+// MultiTrie is not thread-safe.
+type MultiTrie struct {
+	trie *Trie
+	len  int
+}
+
+// multiItems is what is stored in the trie for every key, never empty.
+type multiItems []Item
+
+// Public API ------------------------------------------------------------------
+
+// NewMultiTrie creates an empty MultiTrie. The options are passed to the trie
+// storing the items, except that aggregators are not supported.
+func NewMultiTrie(options ...Option) *MultiTrie {
+	return &MultiTrie{
+		trie: NewTrie(options...),
+	}
+}
+
+// Add adds item to the items stored under key.
+func (trie *MultiTrie) Add(key Prefix, item Item) {
+	if item == nil {
+		return
+	}
+	trie.trie.Update(key, func(current Item, exists bool) (Item, bool) {
+		var items multiItems
+		if exists {
+			items = current.(multiItems)
+		}
+		return append(items, item), true
+	})
+	trie.len++
+}
+
+// RemoveValue removes the first occurrence of item from the items stored
+// under key. It returns true when there was any.
+func (trie *MultiTrie) RemoveValue(key Prefix, item Item) (removed bool) {
+	trie.trie.Update(key, func(current Item, exists bool) (Item, bool) {
+		if !exists {
+			return nil, false
+		}
+		items := current.(multiItems)
+		for i, value := range items {
+			if value == item {
+				removed = true
+				items = append(items[:i:i], items[i+1:]...)
+				break
+			}
+		}
+		return items, len(items) != 0
+	})
+	if removed {
+		trie.len--
+	}
+	return
+}
+
+// RemoveAll removes all the items stored under key, returning their count.
+func (trie *MultiTrie) RemoveAll(key Prefix) (removed int) {
+	if item, deleted := trie.trie.Remove(key); deleted {
+		removed = len(item.(multiItems))
+		trie.len -= removed
+	}
+	return
+}
+
+// GetAll returns the items stored under key in the order they were added,
+// nil when there are none. The slice returned belongs to the caller.
+func (trie *MultiTrie) GetAll(key Prefix) []Item {
+	item := trie.trie.Get(key)
+	if item == nil {
+		return nil
+	}
+	return append([]Item(nil), item.(multiItems)...)
+}
+
+// Len returns the number of items stored, counting every item added
+// under every key.
+func (trie *MultiTrie) Len() int {
+	return trie.len
+}
+
+// Visit calls visitor on every key and item pair, see Trie.Visit.
+// The items stored under the same key are visited in the order they were
+// added. Returning SkipSubtree skips the rest of the items under the key
+// as well as the subtree.
+func (trie *MultiTrie) Visit(visitor VisitorFunc) error {
+	return trie.trie.Visit(eachItem(visitor))
+}
+
+// VisitSubtree works like Visit, but it only visits the keys
+// starting with prefix, see Trie.VisitSubtree.
+func (trie *MultiTrie) VisitSubtree(prefix Prefix, visitor VisitorFunc) error {
+	return trie.trie.VisitSubtree(prefix, eachItem(visitor))
+}
+
+// VisitPrefixes works like Visit, but it only visits the keys that are
+// prefixes of key, see Trie.VisitPrefixes.
+func (trie *MultiTrie) VisitPrefixes(key Prefix, visitor VisitorFunc) error {
+	return trie.trie.VisitPrefixes(key, eachItem(visitor))
+}
+
+// Internal helper functions ---------------------------------------------------
+
+// eachItem turns visitor into one visiting the items stored under every key.
+func eachItem(visitor VisitorFunc) VisitorFunc {
+	return func(key Prefix, item Item) error {
+		for _, value := range item.(multiItems) {
+			if err := visitor(key, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
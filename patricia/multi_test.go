@@ -0,0 +1,110 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Tests -----------------------------------------------------------------------
+
+func TestMultiTrie(t *testing.T) {
+	trie := NewMultiTrie()
+
+	pairs := []struct {
+		key  string
+		item Item
+	}{
+		{"Pepa", 1},
+		{"Pepa", 2},
+		{"Pepa", 1},
+		{"Pepa Novak", 3},
+		{"Karel", 4},
+		{"Karel", nil},
+	}
+	for _, pair := range pairs {
+		t.Logf("ADD prefix=%v, item=%v", pair.key, pair.item)
+		trie.Add(Prefix(pair.key), pair.item)
+	}
+	if n := trie.Len(); n != 5 {
+		t.Errorf("Unexpected length, expected=5, got=%v", n)
+	}
+
+	if items := trie.GetAll(Prefix("Pepa")); !reflect.DeepEqual(items, []Item{1, 2, 1}) {
+		t.Errorf("Unexpected items, expected=%v, got=%v", []Item{1, 2, 1}, items)
+	}
+	if items := trie.GetAll(Prefix("Pep")); items != nil {
+		t.Errorf("Unexpected items, expected=nil, got=%v", items)
+	}
+
+	if !trie.RemoveValue(Prefix("Pepa"), 1) {
+		t.Error("RemoveValue failed")
+	}
+	if trie.RemoveValue(Prefix("Pepa"), 5) || trie.RemoveValue(Prefix("Honza"), 1) {
+		t.Error("RemoveValue removed a missing item")
+	}
+	if items := trie.GetAll(Prefix("Pepa")); !reflect.DeepEqual(items, []Item{2, 1}) {
+		t.Errorf("Unexpected items, expected=%v, got=%v", []Item{2, 1}, items)
+	}
+
+	// Removing the last item removes the key.
+	if !trie.RemoveValue(Prefix("Karel"), 4) {
+		t.Error("RemoveValue failed")
+	}
+	if trie.trie.Match(Prefix("Karel")) {
+		t.Error("Key left behind with no items")
+	}
+	if n := trie.Len(); n != 3 {
+		t.Errorf("Unexpected length, expected=3, got=%v", n)
+	}
+
+	if removed := trie.RemoveAll(Prefix("Pepa Novak")); removed != 1 {
+		t.Errorf("Unexpected number of items removed, expected=1, got=%v", removed)
+	}
+	if n := trie.Len(); n != 2 {
+		t.Errorf("Unexpected length, expected=2, got=%v", n)
+	}
+}
+
+func TestMultiTrie_Visit(t *testing.T) {
+	trie := NewMultiTrie()
+	trie.Add(Prefix("a"), 1)
+	trie.Add(Prefix("a"), 2)
+	trie.Add(Prefix("ab"), 3)
+	trie.Add(Prefix("b"), 4)
+	trie.Add(Prefix("b"), 5)
+
+	var visited []Item
+	trie.Visit(func(prefix Prefix, item Item) error {
+		visited = append(visited, item)
+		if item == 1 {
+			return SkipSubtree
+		}
+		return nil
+	})
+	if expected := []Item{1, 4, 5}; !reflect.DeepEqual(visited, expected) {
+		t.Errorf("Unexpected items visited, expected=%v, got=%v", expected, visited)
+	}
+
+	var keys []string
+	trie.VisitPrefixes(Prefix("abc"), func(prefix Prefix, item Item) error {
+		keys = append(keys, string(prefix))
+		return nil
+	})
+	if expected := []string{"a", "a", "ab"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected keys visited, expected=%q, got=%q", expected, keys)
+	}
+
+	visited = nil
+	trie.VisitSubtree(Prefix("a"), func(prefix Prefix, item Item) error {
+		visited = append(visited, item)
+		return nil
+	})
+	if expected := []Item{1, 2, 3}; !reflect.DeepEqual(visited, expected) {
+		t.Errorf("Unexpected items visited, expected=%v, got=%v", expected, visited)
+	}
+}
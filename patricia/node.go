@@ -0,0 +1,93 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+//------------------------------------------------------------------------------
+// Node
+//------------------------------------------------------------------------------
+
+// Node is a read-only handle to a node of the trie, which makes it possible
+// to walk the trie structure directly, e.g. for custom searches.
+// The key represented by a node is the concatenation of the prefixes
+// of all the nodes on the path from the root down to the node.
+//
+// A Node is only valid until the trie is modified. When the trie is created
+// using ReverseKeys, the prefixes are stored reversed as well.
+type Node struct {
+	node *Trie
+	// key is the key the node represents, only tracked with KeyOrder.
+	key Prefix
+}
+
+// Public API ------------------------------------------------------------------
+
+// Root returns the root node of the trie. The root of an empty trie has
+// an empty prefix, no item and no children.
+func (trie *Trie) Root() Node {
+	return Node{trie, trie.childKey(nil, trie)}
+}
+
+// Prefix returns the part of the key represented by the node that follows
+// the key represented by its parent. The prefix must not be modified.
+func (node Node) Prefix() Prefix {
+	return node.node.prefix
+}
+
+// Item returns the item stored in the node, nil when there is none.
+func (node Node) Item() Item {
+	return node.node.item
+}
+
+// HasItem returns true when there is an item stored in the node.
+// Nodes without items only exist to join the nodes below them.
+func (node Node) HasItem() bool {
+	return node.node.item != nil
+}
+
+// Summary returns the summary of the subtree rooted at the node,
+// see Aggregation.
+func (node Node) Summary() Summary {
+	return node.node.summary
+}
+
+// Children returns the children of the node in key order.
+func (node Node) Children() []Node {
+	children := node.node.ordered(node.key)
+	if len(children) == 0 {
+		return nil
+	}
+
+	nodes := make([]Node, len(children))
+	for i, child := range children {
+		nodes[i] = Node{child, node.node.childKey(node.key, child)}
+	}
+	return nodes
+}
+
+// Child returns the child of the node with the prefix starting with b.
+// ok is false when there is no such child.
+func (node Node) Child(b byte) (child Node, ok bool) {
+	if next := node.node.children.next(b); next != nil {
+		return Node{next, node.node.childKey(node.key, next)}, true
+	}
+	return Node{}, false
+}
+
+// NumChildren returns the number of children of the node.
+func (node Node) NumChildren() int {
+	return node.node.children.length()
+}
+
+// Internal helper methods -----------------------------------------------------
+
+// childKey returns the key child represents, key being the key
+// of its parent, unless there is no KeyOrder to need it.
+func (trie *Trie) childKey(key Prefix, child *Trie) Prefix {
+	if trie.keyOrder == nil {
+		return nil
+	}
+	return append(key[:len(key):len(key)], child.prefix...)
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Tests -----------------------------------------------------------------------
+
+func TestNode_Walk(t *testing.T) {
+	items := map[string]Item{"": 0, "Pepa": 1, "Pepa Zdepa": 2, "Pepa Kuchar": 3, "Honza": 4}
+
+	for _, options := range [][]Option{nil, {MaxPrefixPerNode(2), MaxChildrenPerSparseNode(1)}} {
+		trie := trieOf(items, options...)
+
+		// Collect the keys walking the nodes, in alphabetical order.
+		var keys []string
+		var walk func(node Node, key string)
+		walk = func(node Node, key string) {
+			key += string(node.Prefix())
+			if node.HasItem() {
+				if item := node.Item(); item != items[key] {
+					t.Errorf("Unexpected item, key=%q, expected=%v, got=%v", key, items[key], item)
+				}
+				keys = append(keys, key)
+			}
+			children := node.Children()
+			if len(children) != node.NumChildren() {
+				t.Errorf("Unexpected number of children, expected=%v, got=%v", node.NumChildren(), len(children))
+			}
+			for _, child := range children {
+				walk(child, key)
+			}
+		}
+		walk(trie.Root(), "")
+
+		if expected := visitedKeys(trie); !reflect.DeepEqual(keys, expected) {
+			t.Errorf("Unexpected keys, expected=%q, got=%q", expected, keys)
+		}
+	}
+}
+
+func TestNode_Child(t *testing.T) {
+	trie := trieOf(map[string]Item{"Pepa": 1, "Pepa Zdepa": 2, "Honza": 3})
+
+	root := trie.Root()
+	if len(root.Prefix()) != 0 {
+		t.Errorf("Unexpected root prefix: %q", root.Prefix())
+	}
+
+	pepa, ok := root.Child('P')
+	if !ok || string(pepa.Prefix()) != "Pepa" || pepa.Item() != 1 {
+		t.Errorf("Unexpected child: %q %v", pepa.Prefix(), pepa.Item())
+	}
+	if _, ok := root.Child('X'); ok {
+		t.Error("Unexpected child found")
+	}
+}
+
+func TestNode_EmptyTrie(t *testing.T) {
+	root := NewTrie().Root()
+	if root.HasItem() || len(root.Prefix()) != 0 || len(root.Children()) != 0 {
+		t.Error("Root of an empty trie not empty")
+	}
+}
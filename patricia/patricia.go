@@ -38,6 +38,20 @@ type Trie struct {
 
 	maxPrefixPerNode         int
 	maxChildrenPerSparseNode int
+	arena                    *arena
+	copyKeys                 bool
+	reverseKeys              bool
+	caseInsensitive          bool
+	keyOrder                 LessFunc
+	aggregator               Aggregator
+	hash                     func(item Item) uint64
+
+	// summary describes the whole subtree when aggregator is set.
+	summary Summary
+	// fingerprint describes the whole subtree when hash is set, see Diff.
+	fingerprint uint64
+	// original is the key the item was stored under when caseInsensitive.
+	original Prefix
 
 	children childList
 }
@@ -48,7 +62,7 @@ type Option func(*Trie)
 
 // Trie constructor.
 func NewTrie(options ...Option) *Trie {
-	trie := &Trie{}
+	trie := &Trie{copyKeys: true}
 
 	for _, opt := range options {
 		opt(trie)
@@ -61,7 +75,7 @@ func NewTrie(options ...Option) *Trie {
 		trie.maxChildrenPerSparseNode = DefaultMaxChildrenPerSparseNode
 	}
 
-	trie.children = newSparseChildList(trie.maxChildrenPerSparseNode)
+	trie.children = newSparseChildList(trie.arena, trie.maxChildrenPerSparseNode)
 	return trie
 }
 
@@ -77,16 +91,108 @@ func MaxChildrenPerSparseNode(value int) Option {
 	}
 }
 
+// CopyKeys sets whether keys are copied on insert, which is the default.
+//
+// When disabled, the trie keeps referencing the key slices passed into Insert
+// and Set, so the caller must never modify those afterwards.
+func CopyKeys(value bool) Option {
+	return func(trie *Trie) {
+		trie.copyKeys = value
+	}
+}
+
+// ReverseKeys makes the trie store all keys reversed, so that the operations
+// working with prefixes work with suffixes instead. For example VisitSubtree
+// visits all keys ending with the given suffix and VisitPrefixes visits
+// all keys that are suffixes of the given key.
+//
+// Keys are reversed transparently, visitors receive them in their original
+// orientation. Anything depending on the key order, e.g. the visiting order
+// or the order required by BuildFromSorted, refers to the reversed keys.
+// Tries combined using Merge and friends or compared using Diff must agree
+// on this option.
+func ReverseKeys() Option {
+	return func(trie *Trie) {
+		trie.reverseKeys = true
+	}
+}
+
+// CaseInsensitive makes the trie fold ASCII letters to lower case in all keys
+// used for storing and looking up items, so that keys differing only in case
+// are the same key. The key passed when the item was last set is kept along
+// with the item and passed to visitors. Insert does not change the casing
+// kept when the key is present already.
+//
+// Only ASCII letters are folded, see RuneTrie for Unicode case folding.
+func CaseInsensitive() Option {
+	return func(trie *Trie) {
+		trie.caseInsensitive = true
+	}
+}
+
+// LessFunc reports whether key a goes before key b.
+type LessFunc func(a, b Prefix) bool
+
+// KeyOrder sets the order in which the children of every node are visited,
+// which is the bytewise order by default. Sibling subtrees are ordered by
+// the first keys stored in them in the bytewise order, passed to less in full,
+// so less can implement e.g. locale collation or numeric-aware ordering.
+// The keys passed are encoded the same way the keys stored are, e.g. reversed
+// when ReverseKeys is used. less must be a strict weak ordering, the keys
+// it considers equal are ordered bytewise.
+//
+// The keys in a subtree are always visited together, less only orders whole
+// subtrees. For example a numeric-aware ordering visits "file9" before
+// "file10", but "file1" and "file10", both in the "file1" subtree, are visited
+// before "file2". Since the subtrees are ordered by their first keys, inserting
+// a key can change the order in which the other keys are visited.
+//
+// This covers all the walks, e.g. Visit, VisitSubtree, Diff, Node.Children
+// or the order in which aggregators combine summaries. TopK compares the keys
+// of items with the same score using less directly. BuildFromSorted
+// and BulkInsert still require the bytewise order. Orderings expressible
+// byte by byte are cheaper to get by encoding the keys, see package keyenc.
+func KeyOrder(less LessFunc) Option {
+	return func(trie *Trie) {
+		trie.keyOrder = less
+	}
+}
+
+// SlabAllocator makes the trie carve its nodes, child lists and prefix bytes
+// out of large slabs, each holding room for slabSize nodes. This keeps
+// the number of heap objects the garbage collector must track low for huge
+// tries. The price is that memory occupied by deleted nodes is not reclaimed
+// until Compact or Release is called. The items stored in deleted nodes
+// are not kept reachable, though.
+func SlabAllocator(slabSize int) Option {
+	return func(trie *Trie) {
+		trie.arena = newArena(slabSize)
+	}
+}
+
 // Clone makes a copy of an existing trie.
 // Items stored in both tries become shared, obviously.
 func (trie *Trie) Clone() *Trie {
-	return &Trie{
-		prefix:                   append(Prefix(nil), trie.prefix...),
-		item:                     trie.item,
-		maxPrefixPerNode:         trie.maxPrefixPerNode,
-		maxChildrenPerSparseNode: trie.maxChildrenPerSparseNode,
-		children:                 trie.children.clone(),
+	return trie.clone(trie.arena)
+}
+
+// Compact moves the trie into fresh slabs, dropping the memory occupied
+// by the nodes deleted so far. It does nothing unless SlabAllocator is used.
+func (trie *Trie) Compact() {
+	if trie.arena == nil {
+		return
 	}
+	*trie = *trie.clone(newArena(trie.arena.slabSize))
+}
+
+// Release empties the trie. When SlabAllocator is used, all the slabs
+// are dropped as well so that they can be garbage collected.
+func (trie *Trie) Release() {
+	if trie.arena != nil {
+		trie.arena = newArena(trie.arena.slabSize)
+	}
+	trie.item = nil
+	trie.reset()
 }
 
 // Item returns the item stored in the root of this trie.
@@ -97,13 +203,42 @@ func (trie *Trie) Item() Item {
 // Insert inserts a new item into the trie using the given prefix. Insert does
 // not replace existing items. It returns false if an item was already in place.
 func (trie *Trie) Insert(key Prefix, item Item) (inserted bool) {
-	return trie.put(key, item, false)
+	stored, original := trie.encodeOriginal(key)
+	return trie.put(stored, original, item, false)
 }
 
 // Set works much like Insert, but it always sets the item, possibly replacing
 // the item previously inserted.
 func (trie *Trie) Set(key Prefix, item Item) {
-	trie.put(key, item, true)
+	stored, original := trie.encodeOriginal(key)
+	trie.put(stored, original, item, true)
+}
+
+// Swap works much like Set, but it also returns the item being replaced,
+// replaced being false when there was no item stored under key.
+func (trie *Trie) Swap(key Prefix, item Item) (old Item, replaced bool) {
+	stored, original := trie.encodeOriginal(key)
+	trie.update(stored, original, true, func(current Item, exists bool) (Item, bool) {
+		old, replaced = current, exists
+		return item, true
+	})
+	return
+}
+
+// UpdateFunc is passed the item stored under a key, exists being false when
+// there is none. It returns the item to be stored and whether to keep the key
+// at all. Returning a nil item is the same as returning keep == false.
+type UpdateFunc func(old Item, exists bool) (new Item, keep bool)
+
+// Update locates the node representing key, creating it when necessary,
+// and sets its item to what fn returns. The tree is only walked once, unless
+// the key ends up being deleted because fn returns keep == false,
+// in which case the usual compaction takes place as for Delete.
+//
+// Nothing is inserted when the key is not present and keep is false.
+func (trie *Trie) Update(key Prefix, fn UpdateFunc) {
+	stored, original := trie.encodeOriginal(key)
+	trie.update(stored, original, true, fn)
 }
 
 // Get returns the item located at key.
@@ -115,7 +250,7 @@ func (trie *Trie) Set(key Prefix, item Item) {
 // nil interface as a valid value, even using zero value of any type is enough
 // to prevent this bad behaviour.
 func (trie *Trie) Get(key Prefix) (item Item) {
-	_, node, found, leftover := trie.findSubtree(key)
+	_, node, found, leftover := trie.findSubtree(trie.encodeKey(key))
 	if !found || len(leftover) != 0 {
 		return nil
 	}
@@ -131,19 +266,19 @@ func (trie *Trie) Match(prefix Prefix) (matchedExactly bool) {
 // MatchSubtree returns true when there is a subtree representing extensions
 // to key, that is if there are any keys in the tree which have key as prefix.
 func (trie *Trie) MatchSubtree(key Prefix) (matched bool) {
-	_, _, matched, _ = trie.findSubtree(key)
+	_, _, matched, _ = trie.findSubtree(trie.encodeKey(key))
 	return
 }
 
 // Visit calls visitor on every node containing a non-nil item
-// in alphabetical order.
+// in alphabetical order, or in the order set using KeyOrder.
 //
 // If an error is returned from visitor, the function stops visiting the tree
 // and returns that error, unless it is a special error - SkipSubtree. In that
 // case Visit skips the subtree represented by the current node and continues
 // elsewhere.
 func (trie *Trie) Visit(visitor VisitorFunc) error {
-	return trie.walk(nil, visitor)
+	return trie.walk(nil, trie.decodeVisitor(visitor))
 }
 
 func (trie *Trie) size() int {
@@ -174,14 +309,15 @@ func (trie *Trie) VisitSubtree(prefix Prefix, visitor VisitorFunc) error {
 	}
 
 	// Locate the relevant subtree.
+	prefix = trie.encodeKey(prefix)
 	_, root, found, leftover := trie.findSubtree(prefix)
 	if !found {
 		return nil
 	}
-	prefix = append(prefix, leftover...)
+	prefix = append(prefix[:len(prefix):len(prefix)], leftover...)
 
 	// Visit it.
-	return root.walk(prefix, visitor)
+	return root.walk(prefix, trie.decodeVisitor(visitor))
 }
 
 // VisitPrefixes visits only nodes that represent prefixes of key.
@@ -198,6 +334,8 @@ func (trie *Trie) VisitPrefixes(key Prefix, visitor VisitorFunc) error {
 	}
 
 	// Walk the path matching key prefixes.
+	key = trie.encodeKey(key)
+	visitor = trie.decodeVisitor(visitor)
 	node := trie
 	prefix := key
 	offset := 0
@@ -214,7 +352,7 @@ func (trie *Trie) VisitPrefixes(key Prefix, visitor VisitorFunc) error {
 
 		// Call the visitor.
 		if item := node.item; item != nil {
-			if err := visitor(prefix[:offset], item); err != nil {
+			if err := visitor(node.visitKey(prefix[:offset]), item); err != nil {
 				return err
 			}
 		}
@@ -239,6 +377,39 @@ func (trie *Trie) VisitPrefixes(key Prefix, visitor VisitorFunc) error {
 //
 // True is returned if the matching node was found and deleted.
 func (trie *Trie) Delete(key Prefix) (deleted bool) {
+	_, deleted = trie.Remove(key)
+	return
+}
+
+// Remove works much like Delete, but it also returns the item deleted.
+func (trie *Trie) Remove(key Prefix) (item Item, deleted bool) {
+	return trie.remove(trie.encodeKey(key))
+}
+
+// DeleteSubtree finds the subtree exactly matching prefix and deletes it.
+//
+// True is returned if the subtree was found and deleted.
+func (trie *Trie) DeleteSubtree(prefix Prefix) (deleted bool) {
+	deleted, _ = trie.deleteSubtree(trie.encodeKey(prefix), nil)
+	return
+}
+
+// RemoveSubtree works much like DeleteSubtree, but it returns the number
+// of items deleted. Unless cleanup is nil, it is called for every item deleted,
+// in key order, before the subtree is unlinked from the trie.
+func (trie *Trie) RemoveSubtree(prefix Prefix, cleanup func(key Prefix, item Item)) (removed int) {
+	_, removed = trie.deleteSubtree(trie.encodeKey(prefix), trie.decodeVisitor(func(key Prefix, item Item) error {
+		if cleanup != nil {
+			cleanup(key, item)
+		}
+		return nil
+	}))
+	return
+}
+
+// Internal helper methods -----------------------------------------------------
+
+func (trie *Trie) remove(key Prefix) (item Item, deleted bool) {
 	// Nil prefix not allowed.
 	if key == nil {
 		panic(ErrNilPrefix)
@@ -246,13 +417,18 @@ func (trie *Trie) Delete(key Prefix) (deleted bool) {
 
 	// Empty trie must be handled explicitly.
 	if trie.prefix == nil {
-		return false
+		return nil, false
 	}
 
-	// Find the relevant node.
-	path, found, _ := trie.findSubtreePath(key)
-	if !found {
-		return false
+	// Fix the summaries once done.
+	if trie.summarized() {
+		defer trie.aggregatePath(key)
+	}
+
+	// Find the relevant node, the key must not end in the middle of its prefix.
+	path, found, leftover := trie.findSubtreePath(key)
+	if !found || len(leftover) != 0 {
+		return nil, false
 	}
 
 	node := path[len(path)-1]
@@ -263,11 +439,13 @@ func (trie *Trie) Delete(key Prefix) (deleted bool) {
 
 	// If the item is already set to nil, there is nothing to do.
 	if node.item == nil {
-		return false
+		return nil, false
 	}
 
 	// Delete the item.
+	item = node.item
 	node.item = nil
+	node.original = nil
 
 	// Initialise i before goto.
 	// Will be used later in a loop.
@@ -282,7 +460,7 @@ func (trie *Trie) Delete(key Prefix) (deleted bool) {
 	// In case we are at the root, just reset it and we are done.
 	if parent == nil {
 		node.reset()
-		return true
+		return item, true
 	}
 
 	// We can drop a subtree.
@@ -298,7 +476,7 @@ func (trie *Trie) Delete(key Prefix) (deleted bool) {
 	// In other words, we can reset the whole tree.
 	if i == -1 {
 		path[0].reset()
-		return true
+		return item, true
 	}
 
 	// We can just remove the subtree here.
@@ -325,13 +503,12 @@ Compact:
 		}
 	}
 
-	return true
+	return item, true
 }
 
-// DeleteSubtree finds the subtree exactly matching prefix and deletes it.
-//
-// True is returned if the subtree was found and deleted.
-func (trie *Trie) DeleteSubtree(prefix Prefix) (deleted bool) {
+// deleteSubtree deletes the subtree matching prefix. Unless visitor is nil,
+// it visits the items being deleted and counts them.
+func (trie *Trie) deleteSubtree(prefix Prefix, visitor VisitorFunc) (deleted bool, removed int) {
 	// Nil prefix not allowed.
 	if prefix == nil {
 		panic(ErrNilPrefix)
@@ -339,27 +516,56 @@ func (trie *Trie) DeleteSubtree(prefix Prefix) (deleted bool) {
 
 	// Empty trie must be handled explicitly.
 	if trie.prefix == nil {
-		return false
+		return false, 0
+	}
+
+	// Fix the summaries once done.
+	if trie.summarized() {
+		defer trie.aggregatePath(prefix)
 	}
 
 	// Locate the relevant subtree.
-	parent, root, found, _ := trie.findSubtree(prefix)
+	parent, root, found, leftover := trie.findSubtree(prefix)
 	if !found {
-		return false
+		return false, 0
 	}
 
+	// Visit the items being deleted.
+	if visitor != nil {
+		root.walk(append(prefix[:len(prefix):len(prefix)], leftover...), func(key Prefix, item Item) error {
+			removed++
+			return visitor(key, item)
+		})
+	}
+
+	// The nodes stay in the slabs, the items they hold must not.
+	root.unlinked()
+
 	// If we are in the root of the trie, reset the trie.
 	if parent == nil {
+		root.item = nil
 		root.reset()
-		return true
+		return true, removed
 	}
 
 	// Otherwise remove the root node from its parent.
 	parent.children.remove(root.prefix[0])
-	return true
+	return true, removed
 }
 
-// Internal helper methods -----------------------------------------------------
+// unlinked is called for subtrees unlinked from the trie. When the nodes are
+// carved out of slabs, they are not garbage collected until Compact or Release
+// is called, so the items are dropped right away not to keep them reachable.
+func (trie *Trie) unlinked() {
+	if trie.arena == nil {
+		return
+	}
+	trie.item = nil
+	trie.original = nil
+	for _, child := range trie.children.sorted() {
+		child.unlinked()
+	}
+}
 
 func (trie *Trie) empty() bool {
 	return trie.item == nil && trie.children.length() == 0
@@ -367,10 +573,228 @@ func (trie *Trie) empty() bool {
 
 func (trie *Trie) reset() {
 	trie.prefix = nil
-	trie.children = newSparseChildList(trie.maxPrefixPerNode)
+	trie.summary = nil
+	trie.fingerprint = 0
+	trie.original = nil
+	trie.children = newSparseChildList(trie.arena, trie.maxChildrenPerSparseNode)
+}
+
+// allocNode returns a zeroed node, carved out of the arena when available.
+func (trie *Trie) allocNode() *Trie {
+	if trie.arena != nil {
+		return trie.arena.node()
+	}
+	return new(Trie)
 }
 
-func (trie *Trie) put(key Prefix, item Item, replace bool) (inserted bool) {
+// newNode returns an empty node sharing the configuration of trie.
+func (trie *Trie) newNode() *Trie {
+	node := trie.allocNode()
+	node.maxPrefixPerNode = trie.maxPrefixPerNode
+	node.maxChildrenPerSparseNode = trie.maxChildrenPerSparseNode
+	node.arena = trie.arena
+	node.copyKeys = trie.copyKeys
+	node.reverseKeys = trie.reverseKeys
+	node.caseInsensitive = trie.caseInsensitive
+	node.keyOrder = trie.keyOrder
+	node.aggregator = trie.aggregator
+	node.hash = trie.hash
+	node.children = newSparseChildList(trie.arena, trie.maxChildrenPerSparseNode)
+	return node
+}
+
+// storePrefix returns key in the form that is saved into a node.
+func (trie *Trie) storePrefix(key Prefix) Prefix {
+	if trie.arena != nil {
+		return trie.arena.prefix(key)
+	}
+	if trie.copyKeys {
+		return append(make(Prefix, 0, len(key)), key...)
+	}
+	// Trim the capacity so that the caller's buffer is never written into.
+	return key[:len(key):len(key)]
+}
+
+// encodeKey returns key in the form used for storing it, i.e. reversed
+// and folded as configured. The key passed in is never modified.
+func (trie *Trie) encodeKey(key Prefix) Prefix {
+	return trie.foldKey(trie.orientKey(key))
+}
+
+// encodeOriginal works like encodeKey, but it also returns the key
+// to be kept as the original key of the item, nil unless caseInsensitive.
+func (trie *Trie) encodeOriginal(key Prefix) (stored, original Prefix) {
+	if !trie.caseInsensitive {
+		return trie.orientKey(key), nil
+	}
+	original = trie.orientKey(key)
+	return trie.foldKey(original), original
+}
+
+// orientKey returns key reversed when reverseKeys.
+func (trie *Trie) orientKey(key Prefix) Prefix {
+	if !trie.reverseKeys || key == nil {
+		return key
+	}
+	return reverseKey(make(Prefix, 0, len(key)), key)
+}
+
+// foldKey returns key folded to lower case when caseInsensitive.
+func (trie *Trie) foldKey(key Prefix) Prefix {
+	if !trie.caseInsensitive || key == nil {
+		return key
+	}
+	folded := make(Prefix, len(key))
+	for i, b := range key {
+		folded[i] = foldByte(b)
+	}
+	return folded
+}
+
+// foldByte folds an ASCII letter to lower case.
+func foldByte(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
+
+// lessKey reports whether key a goes before key b when compared using less
+// directly, see KeyOrder, falling back to the bytewise order.
+func lessKey(less LessFunc, a, b Prefix) bool {
+	if less != nil {
+		switch {
+		case less(a, b):
+			return true
+		case less(b, a):
+			return false
+		}
+	}
+	return string(a) < string(b)
+}
+
+// ordered returns the children of trie, which represents key, in key order.
+func (trie *Trie) ordered(key Prefix) tries {
+	children := trie.children.sorted()
+	orderTries(children, key, trie.keyOrder)
+	return children
+}
+
+// firstKey appends to key, which trie represents, the rest of the first key
+// stored in the subtree in the bytewise order.
+func (trie *Trie) firstKey(key Prefix) Prefix {
+	for node := trie; node.item == nil && node.children.length() != 0; {
+		node = node.children.first()
+		key = append(key, node.prefix...)
+	}
+	return key
+}
+
+// storeOriginal returns the original key in the form that is saved into a node.
+func (trie *Trie) storeOriginal(original Prefix) Prefix {
+	if original == nil {
+		return nil
+	}
+	return trie.storePrefix(original)
+}
+
+// visitKey returns the key to be passed to visitors for the item stored
+// in the node, key being the key the node represents.
+func (trie *Trie) visitKey(key Prefix) Prefix {
+	if trie.original != nil {
+		return trie.original
+	}
+	return key
+}
+
+// decodeKey returns key in its original orientation,
+// reusing dst for the result when the key needs to be reversed.
+func (trie *Trie) decodeKey(dst, key Prefix) Prefix {
+	if !trie.reverseKeys {
+		return key
+	}
+	return reverseKey(dst[:0], key)
+}
+
+// decodeVisitor wraps visitor so that it receives keys in their original
+// orientation.
+func (trie *Trie) decodeVisitor(visitor VisitorFunc) VisitorFunc {
+	if !trie.reverseKeys || visitor == nil {
+		return visitor
+	}
+	var buf Prefix
+	return func(prefix Prefix, item Item) error {
+		buf = trie.decodeKey(buf, prefix)
+		return visitor(buf, item)
+	}
+}
+
+// reverseKey appends key reversed to dst.
+func reverseKey(dst, key Prefix) Prefix {
+	for i := len(key) - 1; i >= 0; i-- {
+		dst = append(dst, key[i])
+	}
+	return dst
+}
+
+func (trie *Trie) clone(a *arena) *Trie {
+	var clone *Trie
+	if a != nil {
+		clone = a.node()
+	} else {
+		clone = new(Trie)
+	}
+
+	*clone = Trie{
+		item:                     trie.item,
+		maxPrefixPerNode:         trie.maxPrefixPerNode,
+		maxChildrenPerSparseNode: trie.maxChildrenPerSparseNode,
+		arena:                    a,
+		copyKeys:                 trie.copyKeys,
+		reverseKeys:              trie.reverseKeys,
+		caseInsensitive:          trie.caseInsensitive,
+		keyOrder:                 trie.keyOrder,
+		aggregator:               trie.aggregator,
+		hash:                     trie.hash,
+		summary:                  trie.summary,
+		fingerprint:              trie.fingerprint,
+		children:                 trie.children.clone(a),
+	}
+
+	// Originals are never modified, only the slabs must not be shared.
+	if trie.original != nil {
+		if a != nil {
+			clone.original = a.prefix(trie.original)
+		} else {
+			clone.original = trie.original
+		}
+	}
+
+	// Keep nil and empty prefixes apart, nil marks an empty trie.
+	if trie.prefix != nil {
+		if a != nil {
+			clone.prefix = a.prefix(trie.prefix)
+		} else {
+			clone.prefix = append(make(Prefix, 0, len(trie.prefix)), trie.prefix...)
+		}
+	}
+	return clone
+}
+
+func (trie *Trie) put(key, original Prefix, item Item, replace bool) (inserted bool) {
+	trie.update(key, original, replace, func(old Item, exists bool) (Item, bool) {
+		if exists && !replace {
+			return old, true
+		}
+		inserted = true
+		return item, true
+	})
+	return
+}
+
+// update implements Update. Unless nil, original is stored as the original key
+// of the item set, replacing the one stored already only if replaceOriginal.
+func (trie *Trie) update(key, original Prefix, replaceOriginal bool, fn UpdateFunc) {
 	// Nil prefix not allowed.
 	if key == nil {
 		panic(ErrNilPrefix)
@@ -380,14 +804,25 @@ func (trie *Trie) put(key Prefix, item Item, replace bool) (inserted bool) {
 		common int
 		node   *Trie = trie
 		child  *Trie
+		item   Item
+		keep   bool
+		whole  = key
 	)
 
+	// Fix the summaries once done, whatever path is taken below.
+	if trie.summarized() {
+		defer trie.aggregatePath(whole)
+	}
+
 	if node.prefix == nil {
+		if item, keep = fn(nil, false); !keep || item == nil {
+			return
+		}
 		if len(key) <= trie.maxPrefixPerNode {
-			node.prefix = key
+			node.prefix = trie.storePrefix(key)
 			goto InsertItem
 		}
-		node.prefix = key[:trie.maxPrefixPerNode]
+		node.prefix = trie.storePrefix(key[:trie.maxPrefixPerNode])
 		key = key[trie.maxPrefixPerNode:]
 		goto AppendChild
 	}
@@ -406,39 +841,45 @@ func (trie *Trie) put(key Prefix, item Item, replace bool) (inserted bool) {
 		// common == len(former key) <-> 0 == len(key)
 		// -> former key == node.prefix
 		if len(key) == 0 {
-			goto InsertItem
+			goto UpdateItem
 		}
 
 		// Check children for matching prefix.
 		child = node.children.next(key[0])
 		if child == nil {
-			goto AppendChild
+			goto NewChild
 		}
 		node = child
 	}
 
+NewChild:
+	// The key is not present, find out whether to insert it at all.
+	if item, keep = fn(nil, false); !keep || item == nil {
+		return
+	}
+	goto AppendChild
+
 SplitPrefix:
+	// The key is not present, find out whether to insert it at all.
+	if item, keep = fn(nil, false); !keep || item == nil {
+		return
+	}
+
 	// Split the prefix if necessary.
-	child = new(Trie)
-	*child = *node
-	*node = *NewTrie()
-	node.prefix = child.prefix[:common]
-	child.prefix = child.prefix[common:]
-	child = child.compact()
-	node.children = node.children.add(child)
+	node.split(common)
 
 AppendChild:
 	// Keep appending children until whole prefix is inserted.
 	// This loop starts with empty node.prefix that needs to be filled.
 	for len(key) != 0 {
-		child := NewTrie()
+		child := trie.newNode()
 		if len(key) <= trie.maxPrefixPerNode {
-			child.prefix = key
+			child.prefix = trie.storePrefix(key)
 			node.children = node.children.add(child)
 			node = child
 			goto InsertItem
 		} else {
-			child.prefix = key[:trie.maxPrefixPerNode]
+			child.prefix = trie.storePrefix(key[:trie.maxPrefixPerNode])
 			key = key[trie.maxPrefixPerNode:]
 			node.children = node.children.add(child)
 			node = child
@@ -446,12 +887,35 @@ AppendChild:
 	}
 
 InsertItem:
-	// Try to insert the item if possible.
-	if replace || node.item == nil {
+	node.item = item
+	node.original = trie.storeOriginal(original)
+	return
+
+UpdateItem:
+	// The node exists, but it may be an internal node with no item set.
+	if item, keep = fn(node.item, node.item != nil); keep && item != nil {
+		if node.item == nil || replaceOriginal {
+			node.original = trie.storeOriginal(original)
+		}
 		node.item = item
-		return true
+		return
+	}
+	if node.item != nil {
+		trie.remove(whole)
 	}
-	return false
+}
+
+// split moves everything but the first n bytes of the prefix into a new child.
+func (trie *Trie) split(n int) {
+	child := trie.allocNode()
+	*child = *trie
+	trie.item = nil
+	trie.original = nil
+	trie.children = newSparseChildList(trie.arena, trie.maxChildrenPerSparseNode)
+	trie.prefix = child.prefix[:n:n]
+	child.prefix = child.prefix[n:]
+	child = child.compact()
+	trie.children = trie.children.add(child)
 }
 
 func (trie *Trie) compact() *Trie {
@@ -475,7 +939,12 @@ func (trie *Trie) compact() *Trie {
 	}
 
 	// Concatenate the prefixes, move the items.
-	child.prefix = append(trie.prefix, child.prefix...)
+	if trie.arena != nil {
+		child.prefix = trie.arena.prefix(trie.prefix, child.prefix)
+	} else {
+		// Make sure append allocates, trie.prefix may share its backing array.
+		child.prefix = append(trie.prefix[:len(trie.prefix):len(trie.prefix)], child.prefix...)
+	}
 	if trie.item != nil {
 		child.item = trie.item
 	}
@@ -569,7 +1038,7 @@ func (trie *Trie) walk(actualRootPrefix Prefix, visitor VisitorFunc) error {
 	// Visit the root first. Not that this works for empty trie as well since
 	// in that case item == nil && len(children) == 0.
 	if trie.item != nil {
-		if err := visitor(prefix, trie.item); err != nil {
+		if err := visitor(trie.visitKey(prefix), trie.item); err != nil {
 			if err == SkipSubtree {
 				return nil
 			}
@@ -578,7 +1047,7 @@ func (trie *Trie) walk(actualRootPrefix Prefix, visitor VisitorFunc) error {
 	}
 
 	// Then continue to the children.
-	return trie.children.walk(&prefix, visitor)
+	return trie.children.walk(&prefix, visitor, trie.keyOrder)
 }
 
 func (trie *Trie) longestCommonPrefixLength(prefix Prefix) (i int) {
@@ -603,4 +1072,11 @@ func (trie *Trie) print(writer io.Writer, indent int) {
 var (
 	SkipSubtree  = errors.New("Skip this subtree")
 	ErrNilPrefix = errors.New("Nil prefix passed into a method call")
+
+	ErrUnsortedKeys = errors.New("Keys not sorted in increasing order")
+	ErrDuplicateKey = errors.New("Duplicate key encountered")
+
+	ErrUnknownIndex    = errors.New("Unknown index")
+	ErrNilScore        = errors.New("Nil score function passed into TopK")
+	ErrScoreAggregated = errors.New("Score function passed into TopK on a MaxScore trie")
 )
@@ -7,6 +7,7 @@ package patricia
 
 import (
 	"math/rand"
+	"reflect"
 	"runtime"
 	"strconv"
 	"testing"
@@ -270,6 +271,34 @@ func TestTrie_DeleteLeakageDense(t *testing.T) {
 	}
 }
 
+func TestTrie_RefillDense(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(Prefix("x"), 0)
+	for i := 0; i < 10; i++ {
+		trie.Insert(Prefix("x"+strconv.Itoa(i)), i)
+	}
+	for i := 9; i >= 0; i-- {
+		if !trie.Delete(Prefix("x" + strconv.Itoa(i))) {
+			t.Fatalf("Delete failed, i=%v", i)
+		}
+	}
+
+	// The dense list is empty now, refilling it must fix its head.
+	trie.Insert(Prefix("x5"), 5)
+	if _, node, _, _ := trie.findSubtree(Prefix("x")); node.children.head() == nil {
+		t.Fatal("Child list head not set")
+	}
+
+	var keys []string
+	trie.Visit(func(prefix Prefix, item Item) error {
+		keys = append(keys, string(prefix))
+		return nil
+	})
+	if expected := []string{"x", "x5"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected keys visited, expected=%q, got=%q", expected, keys)
+	}
+}
+
 func heapAllocatedBytes() uint64 {
 	runtime.GC()
 
@@ -0,0 +1,668 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+import (
+	"bufio"
+	"bytes"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Tests -----------------------------------------------------------------------
+
+func TestTrie_CopyKeysOption(t *testing.T) {
+	if trie := NewTrie(); !trie.copyKeys {
+		t.Error("Keys are not copied by default")
+	}
+	if trie := NewTrie(CopyKeys(false)); trie.copyKeys {
+		t.Error("CopyKeys(false) ignored")
+	}
+}
+
+// bufio.Scanner reuses its buffer for every line scanned.
+func TestTrie_ScannerBufferReuse(t *testing.T) {
+	for _, options := range [][]Option{nil, {SlabAllocator(16)}} {
+		trie := NewTrie(options...)
+
+		lines := []string{
+			"Pepa",
+			"Pepa Zdepa",
+			"Pepa Kuchar",
+			"Honza",
+			"Honza Novak",
+			"Jenik",
+			"Jenik Poustevnicek a jeho velmi dlouhe jmeno",
+		}
+
+		scanner := bufio.NewScanner(strings.NewReader(strings.Join(lines, "\n")))
+		scanner.Buffer(make([]byte, 64), 64)
+		for i := 0; scanner.Scan(); i++ {
+			t.Logf("INSERT prefix=%q, item=%v", scanner.Bytes(), i)
+			if ok := trie.Insert(scanner.Bytes(), i); !ok {
+				t.Fatalf("insert failed, prefix=%q", scanner.Bytes())
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			t.Fatal(err)
+		}
+
+		for i, line := range lines {
+			if item := trie.Get(Prefix(line)); item != i {
+				t.Errorf("Unexpected item, prefix=%q, expected=%v, got=%v", line, i, item)
+			}
+		}
+
+		var visited []string
+		trie.Visit(func(prefix Prefix, item Item) error {
+			visited = append(visited, string(prefix))
+			return nil
+		})
+		if len(visited) != len(lines) {
+			t.Errorf("Unexpected keys visited: %q", visited)
+		}
+	}
+}
+
+func TestTrie_CallerBufferOverwritten(t *testing.T) {
+	trie := NewTrie()
+
+	buf := make([]byte, 0, 64)
+	keys := []string{"abcd", "abxy", "ab", "abcdefgh"}
+	for i, key := range keys {
+		buf = append(buf[:0], key...)
+		trie.Insert(buf, i)
+	}
+
+	// Scribble over the buffer, the trie must not notice.
+	for i := range buf[:cap(buf)] {
+		buf[:cap(buf)][i] = '!'
+	}
+
+	for i, key := range keys {
+		if item := trie.Get(Prefix(key)); item != i {
+			t.Errorf("Unexpected item, prefix=%q, expected=%v, got=%v", key, i, item)
+		}
+	}
+}
+
+func TestTrie_CallerBufferNotWritten(t *testing.T) {
+	for _, copyKeys := range []bool{true, false} {
+		trie := NewTrie(CopyKeys(copyKeys))
+
+		// Every key has spare capacity filled with a canary.
+		keys := make([]Prefix, 0, 4)
+		for _, key := range []string{"a", "ab", "abc", "abcd"} {
+			buf := bytes.Repeat([]byte{'#'}, 16)
+			keys = append(keys, Prefix(buf[:copy(buf, key)]))
+		}
+		for i, key := range keys {
+			trie.Insert(key, i)
+		}
+
+		// Deleting causes compaction, which concatenates prefixes.
+		trie.Delete(Prefix("ab"))
+		trie.Delete(Prefix("abc"))
+
+		// Visiting a subtree extends the prefix passed in.
+		subtree := Prefix("ab")
+		buf := bytes.Repeat([]byte{'#'}, 16)
+		trie.VisitSubtree(Prefix(buf[:copy(buf, subtree)]), func(Prefix, Item) error {
+			return nil
+		})
+		keys = append(keys, Prefix(buf[:len(subtree)]))
+
+		for _, key := range keys {
+			if canary := key[len(key):cap(key)]; !bytes.Equal(canary, bytes.Repeat([]byte{'#'}, len(canary))) {
+				t.Errorf("CopyKeys(%v): caller buffer overwritten: %q", copyKeys, key[:cap(key)])
+			}
+		}
+		if item := trie.Get(Prefix("abcd")); item != 3 {
+			t.Errorf("Unexpected item, expected=3, got=%v", item)
+		}
+	}
+}
+
+func TestTrie_CopyKeysDisabledAliases(t *testing.T) {
+	trie := NewTrie(CopyKeys(false))
+
+	key := Prefix("Pepa")
+	trie.Insert(key, 1)
+
+	// This is exactly what the caller promises not to do.
+	key[0] = 'X'
+
+	if item := trie.Get(Prefix("Xepa")); item != 1 {
+		t.Errorf("Key not aliased with CopyKeys(false), got=%v", item)
+	}
+}
+
+func TestTrie_ReverseKeys(t *testing.T) {
+	trie := NewTrie(ReverseKeys())
+
+	data := []testData{
+		{"example.com", 0, success},
+		{"www.example.com", 1, success},
+		{"mail.example.com", 2, success},
+		{"example.org", 3, success},
+		{"com", 4, success},
+	}
+
+	for _, v := range data {
+		t.Logf("INSERT prefix=%v, item=%v, success=%v", v.key, v.value, v.retVal)
+		if ok := trie.Insert([]byte(v.key), v.value); ok != v.retVal {
+			t.Fatalf("Unexpected return value, expected=%v, got=%v", v.retVal, ok)
+		}
+	}
+
+	if item := trie.Get(Prefix("www.example.com")); item != 1 {
+		t.Errorf("Unexpected item, expected=1, got=%v", item)
+	}
+	if !trie.MatchSubtree(Prefix(".example.com")) {
+		t.Error("Suffix not matched")
+	}
+
+	// VisitSubtree visits the keys ending with the suffix given.
+	var keys []string
+	trie.VisitSubtree(Prefix(".example.com"), func(prefix Prefix, item Item) error {
+		keys = append(keys, string(prefix))
+		return nil
+	})
+	if expected := []string{"mail.example.com", "www.example.com"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected keys visited, expected=%q, got=%q", expected, keys)
+	}
+
+	// VisitPrefixes visits the keys that are suffixes of the key given.
+	keys = nil
+	trie.VisitPrefixes(Prefix("ftp.www.example.com"), func(prefix Prefix, item Item) error {
+		keys = append(keys, string(prefix))
+		return nil
+	})
+	if expected := []string{"com", "example.com", "www.example.com"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected keys visited, expected=%q, got=%q", expected, keys)
+	}
+
+	// Visit goes in the order of the reversed keys.
+	keys = nil
+	trie.Visit(func(prefix Prefix, item Item) error {
+		keys = append(keys, string(prefix))
+		return nil
+	})
+	expected := []string{"example.org", "com", "example.com", "mail.example.com", "www.example.com"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected keys visited, expected=%q, got=%q", expected, keys)
+	}
+
+	keys = nil
+	trie.RemoveSubtree(Prefix(".example.com"), func(key Prefix, item Item) {
+		keys = append(keys, string(key))
+	})
+	if expected := []string{"mail.example.com", "www.example.com"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected keys removed, expected=%q, got=%q", expected, keys)
+	}
+
+	if !trie.Delete(Prefix("example.org")) {
+		t.Error("Delete failed")
+	}
+	if n := trie.size(); n != 2 {
+		t.Errorf("Unexpected size, expected=2, got=%v", n)
+	}
+}
+
+func TestTrie_ReverseKeysCallerKeyUntouched(t *testing.T) {
+	trie := NewTrie(ReverseKeys(), CopyKeys(false))
+
+	key := Prefix("example.com")
+	trie.Insert(key, 1)
+	trie.Get(key)
+	trie.Delete(key)
+	if string(key) != "example.com" {
+		t.Errorf("Caller key modified: %q", key)
+	}
+}
+
+func TestTrie_ReverseKeysBulkInsert(t *testing.T) {
+	trie := NewTrie(ReverseKeys())
+
+	// Sorted by the reversed keys.
+	pairs := []KeyItem{
+		{Prefix("b.a"), 1},
+		{Prefix("a.b"), 2},
+		{Prefix("c.b"), 3},
+	}
+	if err := trie.BulkInsert(pairs); err != nil {
+		t.Fatal(err)
+	}
+	for _, pair := range pairs {
+		if item := trie.Get(pair.Key); item != pair.Item {
+			t.Errorf("Unexpected item, key=%q, expected=%v, got=%v", pair.Key, pair.Item, item)
+		}
+	}
+
+	unsorted := []KeyItem{{Prefix("a.b"), 1}, {Prefix("b.a"), 2}}
+	if err := NewTrie(ReverseKeys()).BulkInsert(unsorted); err != ErrUnsortedKeys {
+		t.Errorf("Unexpected error, expected=%v, got=%v", ErrUnsortedKeys, err)
+	}
+}
+
+func TestTrie_ReverseKeysFilter(t *testing.T) {
+	trie := NewTrie(ReverseKeys())
+	trie.Set(Prefix("ab"), 1)
+	trie.Set(Prefix("cb"), 2)
+
+	var keys []string
+	trie.Filter(func(prefix Prefix, item Item) bool {
+		keys = append(keys, string(prefix))
+		return item == 2
+	})
+	if expected := []string{"ab", "cb"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected keys visited, expected=%q, got=%q", expected, keys)
+	}
+
+	top := trie.TopK(Prefix("b"), 1, func(item Item) float64 { return 0 })
+	if len(top) != 1 || string(top[0].Key) != "cb" {
+		t.Errorf("Unexpected result: %v", top)
+	}
+}
+
+func TestTrie_CaseInsensitive(t *testing.T) {
+	trie := NewTrie(CaseInsensitive())
+
+	data := []testData{
+		{"Pepa", 0, success},
+		{"PEPA", 1, failure},
+		{"Pepa Zdepa", 2, success},
+		{"pepa kuchar", 3, success},
+		{"Honza", 4, success},
+	}
+
+	for _, v := range data {
+		t.Logf("INSERT prefix=%v, item=%v, success=%v", v.key, v.value, v.retVal)
+		if ok := trie.Insert([]byte(v.key), v.value); ok != v.retVal {
+			t.Fatalf("Unexpected return value, expected=%v, got=%v", v.retVal, ok)
+		}
+	}
+
+	if item := trie.Get(Prefix("pEPA zDEPA")); item != 2 {
+		t.Errorf("Unexpected item, expected=2, got=%v", item)
+	}
+	if !trie.MatchSubtree(Prefix("PEPA ")) {
+		t.Error("Subtree not matched")
+	}
+
+	// The keys are visited as inserted, Insert keeping the casing in place.
+	var keys []string
+	trie.VisitSubtree(Prefix("PEPA"), func(prefix Prefix, item Item) error {
+		keys = append(keys, string(prefix))
+		return nil
+	})
+	if expected := []string{"Pepa", "pepa kuchar", "Pepa Zdepa"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected keys visited, expected=%q, got=%q", expected, keys)
+	}
+
+	// Set replaces the casing.
+	trie.Set(Prefix("PEPA"), 5)
+	keys = nil
+	trie.VisitPrefixes(Prefix("pepa kuchar"), func(prefix Prefix, item Item) error {
+		keys = append(keys, string(prefix))
+		return nil
+	})
+	if expected := []string{"PEPA", "pepa kuchar"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected keys visited, expected=%q, got=%q", expected, keys)
+	}
+
+	if !trie.Delete(Prefix("honza")) {
+		t.Error("Delete failed")
+	}
+	if n := trie.size(); n != 3 {
+		t.Errorf("Unexpected size, expected=3, got=%v", n)
+	}
+}
+
+func TestTrie_CaseInsensitiveSetOps(t *testing.T) {
+	a := NewTrie(CaseInsensitive(), ReverseKeys())
+	a.Set(Prefix("Example.COM"), 1)
+	b := a.Clone()
+	b.Set(Prefix("www.EXAMPLE.com"), 2)
+	b.Set(Prefix("EXAMPLE.com"), 3)
+
+	var keys []string
+	Diff(a, b, nil, func(key Prefix, kind DiffKind, old, new Item) error {
+		keys = append(keys, string(key))
+		return nil
+	})
+	if expected := []string{"EXAMPLE.com", "www.EXAMPLE.com"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected keys diffed, expected=%q, got=%q", expected, keys)
+	}
+
+	merged := NewTrie(CaseInsensitive(), ReverseKeys())
+	merged.Merge(b, nil)
+	keys = nil
+	merged.Visit(func(prefix Prefix, item Item) error {
+		keys = append(keys, string(prefix))
+		return nil
+	})
+	if expected := []string{"EXAMPLE.com", "www.EXAMPLE.com"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected keys merged, expected=%q, got=%q", expected, keys)
+	}
+
+	// The casing goes along with the item that wins.
+	for _, tc := range []struct {
+		combine  func(a, b *Trie)
+		a, b     Item
+		expected string
+	}{
+		{func(a, b *Trie) { a.Merge(b, nil) }, 1, 2, "EXAMPLE.com"},
+		{func(a, b *Trie) { a.Intersect(b, nil) }, 1, 2, "Example.COM"},
+		{func(a, b *Trie) {
+			a.Merge(b, func(key Prefix, a, b Item) Item { return a })
+		}, 1, 2, "Example.COM"},
+		{func(a, b *Trie) { a.Merge(b, nil) }, []int{1}, []int{2}, "EXAMPLE.com"},
+	} {
+		a := NewTrie(CaseInsensitive())
+		a.Set(Prefix("Example.COM"), tc.a)
+		b := NewTrie(CaseInsensitive())
+		b.Set(Prefix("EXAMPLE.com"), tc.b)
+
+		tc.combine(a, b)
+		if keys := visitedKeys(a); !reflect.DeepEqual(keys, []string{tc.expected}) {
+			t.Errorf("Unexpected keys combined, expected=%q, got=%q", tc.expected, keys)
+		}
+	}
+}
+
+func TestTrie_CaseInsensitiveMatching(t *testing.T) {
+	trie := NewTrie(CaseInsensitive())
+	trie.Insert(Prefix("Go"), 1)
+	trie.Insert(Prefix("gopher"), 2)
+
+	var matches []string
+	trie.CompileMatcher().Scan([]byte("GOPHERS go"), func(offset int, key Prefix, item Item) error {
+		matches = append(matches, string(key))
+		return nil
+	})
+	if expected := []string{"Go", "gopher", "Go"}; !reflect.DeepEqual(matches, expected) {
+		t.Errorf("Unexpected matches, expected=%q, got=%q", expected, matches)
+	}
+
+	var segments []Item
+	trie.Segment([]byte("GOPHERgO"), func(start, end int, item Item) error {
+		segments = append(segments, item)
+		return nil
+	})
+	if expected := []Item{2, 1}; !reflect.DeepEqual(segments, expected) {
+		t.Errorf("Unexpected segments, expected=%v, got=%v", expected, segments)
+	}
+
+	cursor := trie.Cursor()
+	cursor.Advance('G')
+	if state, item := cursor.Advance('O'); state != CursorMatch || item != 1 {
+		t.Errorf("Unexpected cursor state, expected=%v, got=%v", CursorMatch, state)
+	}
+}
+
+func TestTrie_KeyOrder(t *testing.T) {
+	descending := func(a, b Prefix) bool {
+		return string(a) > string(b)
+	}
+	keys := []string{"a", "ab", "abc", "b", "ba", "c", "d", "e", "f", "g"}
+
+	for _, options := range [][]Option{nil, {MaxChildrenPerSparseNode(2)}} {
+		trie := NewTrie(append(options, KeyOrder(descending))...)
+		for i, key := range keys {
+			trie.Insert(Prefix(key), i)
+		}
+
+		var visited []string
+		trie.Visit(func(prefix Prefix, item Item) error {
+			visited = append(visited, string(prefix))
+			return nil
+		})
+		expected := []string{"g", "f", "e", "d", "c", "b", "ba", "a", "ab", "abc"}
+		if !reflect.DeepEqual(visited, expected) {
+			t.Errorf("Unexpected keys visited, expected=%q, got=%q", expected, visited)
+		}
+
+		var children []byte
+		for _, child := range trie.Root().Children() {
+			children = append(children, child.Prefix()[0])
+		}
+		if string(children) != "gfedcba" {
+			t.Errorf("Unexpected children order: %q", children)
+		}
+	}
+}
+
+func TestTrie_KeyOrderDiffAndTopK(t *testing.T) {
+	descending := func(a, b Prefix) bool {
+		return string(a) > string(b)
+	}
+
+	a := NewTrie(KeyOrder(descending))
+	a.Insert(Prefix("ab"), 1)
+	a.Insert(Prefix("b"), 2)
+	b := a.Clone()
+	b.Delete(Prefix("ab"))
+	b.Insert(Prefix("aa"), 3)
+	b.Insert(Prefix("c"), 4)
+
+	var keys []string
+	Diff(a, b, nil, func(key Prefix, kind DiffKind, old, new Item) error {
+		keys = append(keys, string(key))
+		return nil
+	})
+	if expected := []string{"c", "ab", "aa"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected keys diffed, expected=%q, got=%q", expected, keys)
+	}
+
+	keys = nil
+	for _, scored := range b.TopK(Prefix(""), 3, func(Item) float64 { return 1 }) {
+		keys = append(keys, string(scored.Key))
+	}
+	if expected := []string{"c", "b", "aa"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected top keys, expected=%q, got=%q", expected, keys)
+	}
+}
+
+func TestTrie_KeyOrderSetOps(t *testing.T) {
+	descending := func(a, b Prefix) bool {
+		return string(a) > string(b)
+	}
+
+	a := NewTrie(KeyOrder(descending))
+	a.Insert(Prefix("x"), 1)
+	b := NewTrie(KeyOrder(descending))
+	b.Insert(Prefix("ya"), 2)
+	b.Insert(Prefix("yb"), 3)
+
+	a.Merge(b, nil)
+	for _, key := range []string{"x", "ya", "yb"} {
+		if item := a.Get(Prefix(key)); item == nil {
+			t.Errorf("Key %q lost in Merge", key)
+		}
+	}
+
+	c := NewTrie(KeyOrder(descending))
+	c.Insert(Prefix("yb"), 4)
+	c.Insert(Prefix("ya"), 5)
+	a.Intersect(c, nil)
+
+	var keys []string
+	a.Visit(func(prefix Prefix, item Item) error {
+		keys = append(keys, string(prefix))
+		return nil
+	})
+	if expected := []string{"yb", "ya"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected keys visited, expected=%q, got=%q", expected, keys)
+	}
+}
+
+func TestTrie_KeyOrderWholeKeys(t *testing.T) {
+	keys := []string{"file11", "file9", "file10", "file2"}
+
+	optionSets := [][]Option{
+		nil,
+		{MaxPrefixPerNode(1), MaxChildrenPerSparseNode(2)},
+		{SlabAllocator(16)},
+	}
+	for _, options := range optionSets {
+		options = append(options, KeyOrder(numericLess), Aggregation(concatAggregator{}))
+		trie := NewTrie(options...)
+		for _, key := range keys {
+			trie.Insert(Prefix(key), key)
+		}
+
+		// The subtrees are ordered by their first keys, whatever the nodes are.
+		expected := []string{"file2", "file9", "file10", "file11"}
+		if visited := visitedKeys(trie); !reflect.DeepEqual(visited, expected) {
+			t.Errorf("Unexpected keys visited, expected=%q, got=%q", expected, visited)
+		}
+		if summary := trie.AggregateSubtree(Prefix("file")); summary != strings.Join(expected, ",") {
+			t.Errorf("Summaries not combined in key order: %v", summary)
+		}
+
+		// Keys sharing a prefix stay together.
+		trie.Insert(Prefix("file1"), "file1")
+		expected = []string{"file1", "file10", "file11", "file2", "file9"}
+		if visited := visitedKeys(trie); !reflect.DeepEqual(visited, expected) {
+			t.Errorf("Unexpected keys visited, expected=%q, got=%q", expected, visited)
+		}
+		if summary := trie.AggregateSubtree(Prefix("file")); summary != strings.Join(expected, ",") {
+			t.Errorf("Summaries not combined in key order: %v", summary)
+		}
+	}
+}
+
+func TestTrie_KeyOrderDiffWholeKeys(t *testing.T) {
+	a := trieOf(map[string]Item{"file2": 1, "file10": 2}, KeyOrder(numericLess))
+	b := trieOf(map[string]Item{"file9": 3, "file11": 4}, KeyOrder(numericLess))
+
+	var keys []string
+	Diff(a, b, nil, func(key Prefix, kind DiffKind, old, new Item) error {
+		keys = append(keys, kind.String()+" "+string(key))
+		return nil
+	})
+	expected := []string{"removed file2", "added file9", "removed file10", "added file11"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected keys diffed, expected=%q, got=%q", expected, keys)
+	}
+
+	var children []string
+	b.Merge(a, nil)
+	root := b.Root()
+	for _, child := range root.Children() {
+		children = append(children, string(child.Prefix()))
+	}
+	if expected := []string{"2", "9", "1"}; string(root.Prefix()) != "file" || !reflect.DeepEqual(children, expected) {
+		t.Errorf("Unexpected children, prefix=%q, expected=%q, got=%q", root.Prefix(), expected, children)
+	}
+}
+
+func TestTrie_KeyOrderRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	randomKey := func() string {
+		return "abc"[rnd.Intn(3):][:1] + strconv.Itoa(rnd.Intn(1000))
+	}
+	randomItems := func(n int) map[string]Item {
+		items := make(map[string]Item)
+		for i := 0; i < n; i++ {
+			key := randomKey()
+			items[key] = key
+		}
+		return items
+	}
+
+	checkOrder := func(trie *Trie) {
+		t.Helper()
+		visited := strings.Join(visitedKeys(trie), ",")
+		if summary, _ := trie.AggregateSubtree(Prefix{}).(string); summary != visited {
+			t.Fatalf("Summaries not combined in key order, expected=%v, got=%v", visited, summary)
+		}
+	}
+
+	options := []Option{KeyOrder(numericLess), Aggregation(concatAggregator{}), MaxChildrenPerSparseNode(3)}
+	trie := NewTrie(options...)
+	for round := 0; round < 1000; round++ {
+		key := randomKey()
+		switch op := rnd.Intn(10); {
+		case op < 6:
+			trie.Set(Prefix(key), key)
+		case op < 8:
+			trie.Delete(Prefix(key))
+		case op < 9:
+			trie.DeleteSubtree(Prefix(key[:2]))
+		default:
+			trie.Filter(func(prefix Prefix, item Item) bool {
+				return len(prefix)%3 != 0
+			})
+		}
+		checkOrder(trie)
+	}
+	trie.Merge(trieOf(randomItems(100), options...), nil)
+
+	sorted := visitedKeys(trie)
+	sort.Strings(sorted)
+	var pairs []KeyItem
+	for _, key := range sorted {
+		pairs = append(pairs, KeyItem{Prefix(key), key})
+	}
+	built := NewTrie(options...)
+	if err := built.BulkInsert(pairs); err != nil {
+		t.Fatal(err)
+	}
+
+	// Everything walking the trie must agree on the order.
+	for _, trie := range []*Trie{trie, built, trie.Clone()} {
+		checkOrder(trie)
+
+		visited := visitedKeys(trie)
+
+		var diffed []string
+		Diff(NewTrie(options...), trie, nil, func(key Prefix, kind DiffKind, old, new Item) error {
+			diffed = append(diffed, string(key))
+			return nil
+		})
+		if !reflect.DeepEqual(diffed, visited) {
+			t.Errorf("Keys not diffed in key order, expected=%q, got=%q", visited, diffed)
+		}
+	}
+}
+
+// Helpers ---------------------------------------------------------------------
+
+// numericLess compares keys bytewise, except that runs of digits
+// are compared as numbers.
+func numericLess(a, b Prefix) bool {
+	isDigit := func(c byte) bool {
+		return '0' <= c && c <= '9'
+	}
+	for len(a) != 0 && len(b) != 0 {
+		if !isDigit(a[0]) || !isDigit(b[0]) {
+			if a[0] != b[0] {
+				return a[0] < b[0]
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		i, j := 0, 0
+		for ; i < len(a) && isDigit(a[i]); i++ {
+		}
+		for ; j < len(b) && isDigit(b[j]); j++ {
+		}
+		x, _ := strconv.Atoi(string(a[:i]))
+		y, _ := strconv.Atoi(string(b[:j]))
+		if x != y {
+			return x < y
+		}
+		a, b = a[i:], b[j:]
+	}
+	return len(a) < len(b)
+}
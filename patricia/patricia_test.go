@@ -129,3 +129,194 @@ func TestTrie_DeleteAbsentPrefix(t *testing.T) {
 		t.Errorf("Unexpected item, expected=%v, got=%v", v.value, i)
 	}
 }
+
+func TestTrie_UpdateCounter(t *testing.T) {
+	trie := NewTrie()
+
+	increment := func(old Item, exists bool) (Item, bool) {
+		if !exists {
+			return 1, true
+		}
+		return old.(int) + 1, true
+	}
+
+	for _, key := range []string{"Pepa", "Honza", "Pepa", "Pepa Zdepa", "Pepa"} {
+		t.Logf("UPDATE prefix=%v", key)
+		trie.Update(Prefix(key), increment)
+	}
+
+	expected := map[string]int{"Pepa": 3, "Honza": 1, "Pepa Zdepa": 1}
+	for key, count := range expected {
+		if item := trie.Get(Prefix(key)); item != count {
+			t.Errorf("Unexpected item, prefix=%v, expected=%v, got=%v", key, count, item)
+		}
+	}
+}
+
+func TestTrie_UpdateInternalNode(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(Prefix("Pepa Zdepa"), 1)
+	trie.Insert(Prefix("Pepa Kuchar"), 2)
+
+	// "Pepa " is an internal node without any item.
+	trie.Update(Prefix("Pepa "), func(old Item, exists bool) (Item, bool) {
+		if exists || old != nil {
+			t.Errorf("Unexpected item passed in: %v, %v", old, exists)
+		}
+		return 3, true
+	})
+
+	if item := trie.Get(Prefix("Pepa ")); item != 3 {
+		t.Errorf("Unexpected item, expected=3, got=%v", item)
+	}
+}
+
+func TestTrie_UpdateDelete(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(Prefix("Pepa"), 1)
+	trie.Insert(Prefix("Pepa Zdepa"), 2)
+	trie.Insert(Prefix("Pepa Kuchar"), 3)
+
+	remove := func(old Item, exists bool) (Item, bool) {
+		return nil, false
+	}
+
+	t.Log("UPDATE prefix=Pepa Zdepa, keep=false")
+	trie.Update(Prefix("Pepa Zdepa"), remove)
+	if item := trie.Get(Prefix("Pepa Zdepa")); item != nil {
+		t.Errorf("Unexpected item, expected=<nil>, got=%v", item)
+	}
+
+	// The node must be dropped, leaving "Pepa", " " and "Kuchar".
+	if total := trie.total(); total != 3 {
+		t.Errorf("Node not dropped, total=%v, state:\n%s", total, trie.dump())
+	}
+
+	// Nothing is inserted for a key that is not present.
+	t.Log("UPDATE prefix=Honza, keep=false")
+	trie.Update(Prefix("Honza"), remove)
+	if total := trie.total(); total != 3 {
+		t.Errorf("Unexpected nodes created, total=%v, state:\n%s", total, trie.dump())
+	}
+
+	empty := NewTrie()
+	empty.Update(Prefix("Honza"), remove)
+	if empty.prefix != nil {
+		t.Errorf("Unexpected nodes created in an empty trie:\n%s", empty.dump())
+	}
+}
+
+func TestTrie_Swap(t *testing.T) {
+	trie := NewTrie()
+
+	t.Log("SWAP prefix=Pepa, item=1")
+	if old, replaced := trie.Swap(Prefix("Pepa"), 1); replaced || old != nil {
+		t.Errorf("Unexpected return value, expected=<nil> false, got=%v %v", old, replaced)
+	}
+
+	t.Log("SWAP prefix=Pepa, item=2")
+	if old, replaced := trie.Swap(Prefix("Pepa"), 2); !replaced || old != 1 {
+		t.Errorf("Unexpected return value, expected=1 true, got=%v %v", old, replaced)
+	}
+
+	if item := trie.Get(Prefix("Pepa")); item != 2 {
+		t.Errorf("Unexpected item, expected=2, got=%v", item)
+	}
+}
+
+func TestTrie_Remove(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(Prefix("Pepa"), 1)
+	trie.Insert(Prefix("Pepa Zdepa"), 2)
+
+	t.Log("REMOVE prefix=Pepa Zdepa")
+	if item, ok := trie.Remove(Prefix("Pepa Zdepa")); !ok || item != 2 {
+		t.Errorf("Unexpected return value, expected=2 true, got=%v %v", item, ok)
+	}
+
+	t.Log("REMOVE prefix=Pepa Zdepa")
+	if item, ok := trie.Remove(Prefix("Pepa Zdepa")); ok || item != nil {
+		t.Errorf("Unexpected return value, expected=<nil> false, got=%v %v", item, ok)
+	}
+
+	t.Log("REMOVE prefix=Pepa")
+	if item, ok := trie.Remove(Prefix("Pepa")); !ok || item != 1 {
+		t.Errorf("Unexpected return value, expected=1 true, got=%v %v", item, ok)
+	}
+}
+
+func TestTrie_RemovePartialPrefix(t *testing.T) {
+	trie := NewTrie()
+	trie.Insert(Prefix("abc"), 1)
+
+	// The key ends in the middle of the node prefix, there is no such key.
+	t.Log("REMOVE prefix=ab")
+	if item, ok := trie.Remove(Prefix("ab")); ok || item != nil {
+		t.Errorf("Unexpected return value, expected=<nil> false, got=%v %v", item, ok)
+	}
+	t.Log("DELETE prefix=ab")
+	if ok := trie.Delete(Prefix("ab")); ok {
+		t.Errorf("Unexpected return value, expected=%v, got=%v", failure, ok)
+	}
+
+	if item := trie.Get(Prefix("abc")); item != 1 {
+		t.Errorf("Unexpected item, expected=1, got=%v", item)
+	}
+}
+
+func TestTrie_RemoveSubtree(t *testing.T) {
+	trie := NewTrie()
+
+	data := []testData{
+		{"P", 0, success},
+		{"Pe", 1, success},
+		{"Pep", 2, success},
+		{"Pepa", 3, success},
+		{"Pepa Zdepa", 4, success},
+		{"Pepa Kuchar", 5, success},
+		{"Honza", 6, success},
+		{"Jenik", 7, success},
+	}
+
+	for _, v := range data {
+		t.Logf("INSERT prefix=%v, item=%v, success=%v", v.key, v.value, v.retVal)
+		if ok := trie.Insert([]byte(v.key), v.value); ok != v.retVal {
+			t.Fatalf("Unexpected return value, expected=%v, got=%v", v.retVal, ok)
+		}
+	}
+
+	var cleaned []string
+	cleanup := func(key Prefix, item Item) {
+		if data[item.(int)].key != string(key) {
+			t.Errorf("Unexpected key passed to cleanup: %q", key)
+		}
+		cleaned = append(cleaned, string(key))
+	}
+
+	t.Log("REMOVE_SUBTREE prefix=Pepa")
+	if removed := trie.RemoveSubtree(Prefix("Pepa"), cleanup); removed != 3 {
+		t.Errorf("Unexpected return value, expected=3, got=%v", removed)
+	}
+	expected := []string{"Pepa", "Pepa Kuchar", "Pepa Zdepa"}
+	if !reflect.DeepEqual(cleaned, expected) {
+		t.Errorf("Unexpected keys cleaned up, expected=%q, got=%q", expected, cleaned)
+	}
+
+	t.Log("REMOVE_SUBTREE prefix=Pep")
+	if removed := trie.RemoveSubtree(Prefix("Pep"), nil); removed != 1 {
+		t.Errorf("Unexpected return value, expected=1, got=%v", removed)
+	}
+
+	t.Log("REMOVE_SUBTREE prefix=Nobody")
+	if removed := trie.RemoveSubtree(Prefix("Nobody"), nil); removed != 0 {
+		t.Errorf("Unexpected return value, expected=0, got=%v", removed)
+	}
+
+	t.Log("REMOVE_SUBTREE prefix=")
+	if removed := trie.RemoveSubtree(Prefix(""), nil); removed != 4 {
+		t.Errorf("Unexpected return value, expected=4, got=%v", removed)
+	}
+	if size := trie.size(); size != 0 {
+		t.Errorf("Unexpected trie size, expected=0, got=%v", size)
+	}
+}
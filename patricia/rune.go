@@ -0,0 +1,328 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+import (
+	"sort"
+	"unicode"
+)
+
+//------------------------------------------------------------------------------
+// RuneTrie
+//------------------------------------------------------------------------------
+
+// RuneVisitorFunc is the RuneTrie counterpart of VisitorFunc.
+type RuneVisitorFunc func(key string, item Item) error
+
+// RuneTrie is a patricia trie branching on runes instead of bytes, so that
+// node prefixes never end in the middle of a UTF-8 sequence. Keys are strings,
+// which should be valid UTF-8, invalid bytes are turned into utf8.RuneError.
+//
+// Keys can be normalized on the way in, see Normalizer and FoldCase.
+// The normalized keys are stored and passed to visitors.
+//
+// RuneTrie is not thread-safe.
+type RuneTrie struct {
+	root      runeNode
+	normalize func(key string) string
+	foldCase  bool
+	len       int
+}
+
+// runeNode is a node of RuneTrie. The root has an empty prefix,
+// the children are sorted by the first rune of their prefix.
+type runeNode struct {
+	prefix   []rune
+	item     Item
+	children []*runeNode
+}
+
+// Public API ------------------------------------------------------------------
+
+type RuneOption func(*RuneTrie)
+
+// RuneTrie constructor.
+func NewRuneTrie(options ...RuneOption) *RuneTrie {
+	trie := &RuneTrie{}
+
+	for _, opt := range options {
+		opt(trie)
+	}
+
+	return trie
+}
+
+// Normalizer sets the function applied to all keys, both on insert
+// and on lookup. Use e.g. norm.NFC.String from golang.org/x/text/unicode/norm
+// to make canonically equivalent keys equal.
+func Normalizer(normalize func(key string) string) RuneOption {
+	return func(trie *RuneTrie) {
+		trie.normalize = normalize
+	}
+}
+
+// FoldCase makes the trie case-insensitive using simple case folding,
+// i.e. rune by rune. Keys are stored lowercased. Folding that changes
+// the number of runes, e.g. ß to ss, must be done in a Normalizer.
+// FoldCase is applied after the normalizer.
+func FoldCase() RuneOption {
+	return func(trie *RuneTrie) {
+		trie.foldCase = true
+	}
+}
+
+// Insert inserts a new item into the trie using the given key. Insert does
+// not replace existing items. It returns false if an item was already in place.
+func (trie *RuneTrie) Insert(key string, item Item) (inserted bool) {
+	return trie.put(trie.runes(key), item, false)
+}
+
+// Set works much like Insert, but it always sets the item, possibly replacing
+// the item previously inserted.
+func (trie *RuneTrie) Set(key string, item Item) {
+	trie.put(trie.runes(key), item, true)
+}
+
+// Get returns the item located at key, nil when there is none.
+func (trie *RuneTrie) Get(key string) Item {
+	node, rest := trie.find(trie.runes(key))
+	if node == nil || rest != 0 {
+		return nil
+	}
+	return node.item
+}
+
+// Delete deletes the item represented by the given key.
+//
+// True is returned if the matching node was found and deleted.
+func (trie *RuneTrie) Delete(key string) (deleted bool) {
+	return trie.delete(trie.runes(key))
+}
+
+// Len returns the number of items stored.
+func (trie *RuneTrie) Len() int {
+	return trie.len
+}
+
+// Visit calls visitor on every item in alphabetical order, i.e. in the order
+// of the runes. SkipSubtree and other errors are handled as in Trie.Visit.
+func (trie *RuneTrie) Visit(visitor RuneVisitorFunc) error {
+	return trie.root.walk(make([]rune, 0, 32), visitor)
+}
+
+// VisitSubtree works much like Visit, but it only visits the keys
+// starting with prefix.
+func (trie *RuneTrie) VisitSubtree(prefix string, visitor RuneVisitorFunc) error {
+	runes := trie.runes(prefix)
+	node, rest := trie.find(runes)
+	if node == nil {
+		return nil
+	}
+	key := append(runes, node.prefix[len(node.prefix)-rest:]...)
+	return node.walk(key, visitor)
+}
+
+// VisitPrefixes visits only the keys that are prefixes of key,
+// shortest first.
+func (trie *RuneTrie) VisitPrefixes(key string, visitor RuneVisitorFunc) error {
+	runes := trie.runes(key)
+
+	node := &trie.root
+	offset := 0
+	for {
+		if node.item != nil {
+			if err := visitor(string(runes[:offset]), node.item); err != nil {
+				return err
+			}
+		}
+
+		if offset == len(runes) {
+			return nil
+		}
+		_, child := node.child(runes[offset])
+		if child == nil || !hasRunePrefix(runes[offset:], child.prefix) {
+			return nil
+		}
+		node = child
+		offset += len(child.prefix)
+	}
+}
+
+// Internal helper methods -----------------------------------------------------
+
+// runes returns key normalized and split into runes.
+func (trie *RuneTrie) runes(key string) []rune {
+	if trie.normalize != nil {
+		key = trie.normalize(key)
+	}
+	runes := []rune(key)
+	if trie.foldCase {
+		for i, r := range runes {
+			runes[i] = unicode.ToLower(unicode.ToUpper(r))
+		}
+	}
+	return runes
+}
+
+func (trie *RuneTrie) put(key []rune, item Item, replace bool) (inserted bool) {
+	// Storing nil is the same as deleting, as in Trie.
+	if item == nil {
+		if node, rest := trie.find(key); node != nil && rest == 0 && node.item != nil {
+			if !replace {
+				return false
+			}
+			trie.delete(key)
+		}
+		return true
+	}
+
+	node := &trie.root
+	for len(key) != 0 {
+		i, child := node.child(key[0])
+
+		// No child matches, append the rest of the key as a new child.
+		if child == nil {
+			child = &runeNode{prefix: key}
+			node.children = append(node.children, nil)
+			copy(node.children[i+1:], node.children[i:])
+			node.children[i] = child
+			node = child
+			break
+		}
+
+		// Only a part matches, split.
+		common := commonRunePrefixLength(child.prefix, key)
+		if common < len(child.prefix) {
+			parent := &runeNode{
+				prefix:   child.prefix[:common:common],
+				children: []*runeNode{child},
+			}
+			child.prefix = child.prefix[common:]
+			node.children[i] = parent
+			child = parent
+		}
+
+		node = child
+		key = key[common:]
+	}
+
+	if node.item != nil && !replace {
+		return false
+	}
+	if node.item == nil {
+		trie.len++
+	}
+	node.item = item
+	return true
+}
+
+func (trie *RuneTrie) delete(key []rune) (deleted bool) {
+	if len(key) == 0 {
+		deleted = trie.root.item != nil
+		trie.root.item = nil
+	} else {
+		deleted = trie.root.delete(key)
+	}
+
+	if deleted {
+		trie.len--
+	}
+	return
+}
+
+// find returns the node key ends in, rest being the number of runes
+// of its prefix not covered by key. nil is returned when there is no such node.
+func (trie *RuneTrie) find(key []rune) (node *runeNode, rest int) {
+	node = &trie.root
+	for len(key) != 0 {
+		_, child := node.child(key[0])
+		if child == nil {
+			return nil, 0
+		}
+
+		common := commonRunePrefixLength(child.prefix, key)
+		switch {
+		case common == len(key):
+			return child, len(child.prefix) - common
+		case common < len(child.prefix):
+			return nil, 0
+		}
+		node = child
+		key = key[common:]
+	}
+	return node, 0
+}
+
+// child returns the child with the prefix starting with r, or the index
+// where to insert such a child in case there is none.
+func (node *runeNode) child(r rune) (int, *runeNode) {
+	i := sort.Search(len(node.children), func(i int) bool {
+		return node.children[i].prefix[0] >= r
+	})
+	if i < len(node.children) && node.children[i].prefix[0] == r {
+		return i, node.children[i]
+	}
+	return i, nil
+}
+
+// delete deletes the item stored under key below node,
+// dropping and merging the nodes left without items.
+func (node *runeNode) delete(key []rune) bool {
+	i, child := node.child(key[0])
+	if child == nil || !hasRunePrefix(key, child.prefix) {
+		return false
+	}
+
+	if rest := key[len(child.prefix):]; len(rest) != 0 {
+		if !child.delete(rest) {
+			return false
+		}
+	} else if child.item != nil {
+		child.item = nil
+	} else {
+		return false
+	}
+
+	if child.item == nil {
+		switch len(child.children) {
+		case 0:
+			node.children = append(node.children[:i], node.children[i+1:]...)
+		case 1:
+			grandchild := child.children[0]
+			grandchild.prefix = append(child.prefix[:len(child.prefix):len(child.prefix)], grandchild.prefix...)
+			node.children[i] = grandchild
+		}
+	}
+	return true
+}
+
+func (node *runeNode) walk(key []rune, visitor RuneVisitorFunc) error {
+	if node.item != nil {
+		if err := visitor(string(key), node.item); err != nil {
+			if err == SkipSubtree {
+				return nil
+			}
+			return err
+		}
+	}
+
+	for _, child := range node.children {
+		if err := child.walk(append(key, child.prefix...), visitor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func commonRunePrefixLength(a, b []rune) (i int) {
+	for ; i < len(a) && i < len(b) && a[i] == b[i]; i++ {
+	}
+	return
+}
+
+func hasRunePrefix(key, prefix []rune) bool {
+	return len(key) >= len(prefix) && commonRunePrefixLength(key, prefix) == len(prefix)
+}
@@ -0,0 +1,223 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// Tests -----------------------------------------------------------------------
+
+func TestRuneTrie_InsertGet(t *testing.T) {
+	trie := NewRuneTrie()
+
+	data := []testData{
+		{"Příliš", 0, success},
+		{"Přítel", 1, success},
+		{"Při", 2, success},
+		{"東京", 3, success},
+		{"東京都", 4, success},
+		{"", 5, success},
+		{"Při", 6, failure},
+	}
+
+	for _, v := range data {
+		t.Logf("INSERT prefix=%v, item=%v, success=%v", v.key, v.value, v.retVal)
+		if ok := trie.Insert(v.key, v.value); ok != v.retVal {
+			t.Fatalf("Unexpected return value, expected=%v, got=%v", v.retVal, ok)
+		}
+	}
+
+	for _, v := range data {
+		if !v.retVal {
+			continue
+		}
+		if item := trie.Get(v.key); item != v.value {
+			t.Errorf("Unexpected item, key=%q, expected=%v, got=%v", v.key, v.value, item)
+		}
+	}
+	if item := trie.Get("Př"); item != nil {
+		t.Errorf("Unexpected item for an inner node: %v", item)
+	}
+	if n := trie.Len(); n != 6 {
+		t.Errorf("Unexpected length, expected=6, got=%v", n)
+	}
+
+	checkRunePrefixes(t, &trie.root)
+}
+
+func TestRuneTrie_Visit(t *testing.T) {
+	trie := NewRuneTrie()
+	for i, key := range []string{"Příliš", "Přítel", "Při", "東京", "東京都", "Pes"} {
+		trie.Set(key, i)
+	}
+
+	var keys []string
+	trie.VisitSubtree("Pří", func(key string, item Item) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if expected := []string{"Příliš", "Přítel"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected keys visited, expected=%q, got=%q", expected, keys)
+	}
+
+	keys = nil
+	trie.VisitPrefixes("東京都庁", func(key string, item Item) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if expected := []string{"東京", "東京都"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected keys visited, expected=%q, got=%q", expected, keys)
+	}
+
+	keys = nil
+	trie.Visit(func(key string, item Item) error {
+		keys = append(keys, key)
+		if key == "東京" {
+			return SkipSubtree
+		}
+		return nil
+	})
+	if expected := []string{"Pes", "Při", "Příliš", "Přítel", "東京"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected keys visited, expected=%q, got=%q", expected, keys)
+	}
+}
+
+func TestRuneTrie_FoldCase(t *testing.T) {
+	trie := NewRuneTrie(FoldCase())
+
+	trie.Set("ŽLUŤOUČKÝ Kůň", 1)
+	if item := trie.Get("žluťoučký kůň"); item != 1 {
+		t.Errorf("Unexpected item, expected=1, got=%v", item)
+	}
+	// Kelvin sign folds to k.
+	if item := trie.Get("žluťoučký \u212Aůň"); item != 1 {
+		t.Errorf("Unexpected item, expected=1, got=%v", item)
+	}
+
+	var keys []string
+	trie.Visit(func(key string, item Item) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if expected := []string{"žluťoučký kůň"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected keys visited, expected=%q, got=%q", expected, keys)
+	}
+}
+
+func TestRuneTrie_Normalizer(t *testing.T) {
+	// Composes e followed by a combining acute accent, standing in for NFC.
+	compose := func(key string) string {
+		return strings.ReplaceAll(key, "e\u0301", "\u00e9")
+	}
+	trie := NewRuneTrie(Normalizer(compose), FoldCase())
+
+	trie.Set("Café", 1)
+	if item := trie.Get("CAFÉ"); item != 1 {
+		t.Errorf("Unexpected item, expected=1, got=%v", item)
+	}
+	if !trie.Delete("café") {
+		t.Error("Delete failed")
+	}
+}
+
+func TestRuneTrie_NilItem(t *testing.T) {
+	trie := NewRuneTrie()
+
+	// Storing nil stores nothing.
+	if ok := trie.Insert("a", nil); !ok {
+		t.Error("Insert of nil failed")
+	}
+	if ok := trie.Insert("a", 1); !ok {
+		t.Error("Insert failed")
+	}
+	if n := trie.Len(); n != 1 {
+		t.Errorf("Unexpected length, expected=1, got=%v", n)
+	}
+
+	if ok := trie.Insert("a", nil); ok {
+		t.Error("Insert of nil replaced an item")
+	}
+
+	// Setting nil deletes the item.
+	trie.Set("a", nil)
+	if item := trie.Get("a"); item != nil {
+		t.Errorf("Unexpected item, expected=<nil>, got=%v", item)
+	}
+	if n := trie.Len(); n != 0 {
+		t.Errorf("Unexpected length, expected=0, got=%v", n)
+	}
+}
+
+func TestRuneTrie_DeleteRandom(t *testing.T) {
+	alphabet := []rune("aáč東")
+	items := make(map[string]Item)
+	for i := 0; i < 500; i++ {
+		key := make([]rune, rand.Intn(6))
+		for j := range key {
+			key[j] = alphabet[rand.Intn(len(alphabet))]
+		}
+		items[string(key)] = i
+	}
+
+	trie := NewRuneTrie()
+	for key, item := range items {
+		trie.Set(key, item)
+	}
+
+	var keys []string
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		if i%2 == 0 {
+			if !trie.Delete(key) {
+				t.Fatalf("Delete failed: %q", key)
+			}
+			delete(items, key)
+		}
+	}
+	if trie.Delete("東東東東東東東") {
+		t.Error("Delete of a missing key succeeded")
+	}
+
+	left := make(map[string]Item)
+	trie.Visit(func(key string, item Item) error {
+		left[key] = item
+		return nil
+	})
+	if !reflect.DeepEqual(left, items) {
+		t.Errorf("Unexpected items left, expected=%v, got=%v", items, left)
+	}
+	if n := trie.Len(); n != len(items) {
+		t.Errorf("Unexpected length, expected=%v, got=%v", len(items), n)
+	}
+	checkRunePrefixes(t, &trie.root)
+}
+
+// Helpers ---------------------------------------------------------------------
+
+// checkRunePrefixes makes sure the nodes are compacted and sorted.
+func checkRunePrefixes(t *testing.T, node *runeNode) {
+	for i, child := range node.children {
+		if !utf8.ValidString(string(child.prefix)) || len(child.prefix) == 0 {
+			t.Errorf("Invalid prefix: %q", string(child.prefix))
+		}
+		if child.item == nil && len(child.children) < 2 {
+			t.Errorf("Node not compacted: %q", string(child.prefix))
+		}
+		if i != 0 && node.children[i-1].prefix[0] >= child.prefix[0] {
+			t.Errorf("Children not sorted: %q", string(child.prefix))
+		}
+		checkRunePrefixes(t, child)
+	}
+}
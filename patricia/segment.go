@@ -0,0 +1,127 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+import "unicode/utf8"
+
+//------------------------------------------------------------------------------
+// Segmentation
+//------------------------------------------------------------------------------
+
+// SegmentFunc is called for every segment of the text, the segment being
+// text[start:end]. item is nil for the segments not matching any key.
+type SegmentFunc func(start, end int, item Item) error
+
+// Segment splits text into segments matching the keys stored in the trie,
+// always taking the longest key matching at the current position. When no key
+// matches, a single UTF-8 encoded rune is passed to visitor as an unknown
+// segment with a nil item, a single byte in case the text is not valid UTF-8.
+// The empty key never matches.
+//
+// The keys are matched as stored, so Segment makes no sense for tries created
+// using ReverseKeys.
+//
+// If an error is returned from visitor, Segment stops and returns that error.
+func (trie *Trie) Segment(text []byte, visitor SegmentFunc) error {
+	text = trie.foldKey(text)
+	for start := 0; start < len(text); {
+		end, item := start, Item(nil)
+		trie.matchPrefixes(text[start:], func(n int, it Item) {
+			end, item = start+n, it
+		})
+		if end == start {
+			end = start + unknownLength(text[start:])
+		}
+
+		if err := visitor(start, end, item); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+// SegmentBestPath works like Segment, but instead of being greedy it finds
+// the segmentation of the whole text with the fewest unknown runes and,
+// among those, with the fewest segments. Ties are resolved in favour of longer
+// segments at the beginning of the text.
+func (trie *Trie) SegmentBestPath(text []byte, visitor SegmentFunc) error {
+	text = trie.foldKey(text)
+	type step struct {
+		unknown  int
+		segments int
+		end      int
+		item     Item
+	}
+
+	// best[i] is the best way to segment text[i:], computed back to front.
+	best := make([]step, len(text)+1)
+	better := func(s step, than *step) bool {
+		return s.unknown < than.unknown || s.unknown == than.unknown && s.segments < than.segments
+	}
+
+	for start := len(text) - 1; start >= 0; start-- {
+		n := unknownLength(text[start:])
+		rest := best[start+n]
+		best[start] = step{rest.unknown + 1, rest.segments + 1, start + n, nil}
+
+		// Visited from the shortest key up, so longer keys win ties.
+		trie.matchPrefixes(text[start:], func(n int, item Item) {
+			rest := best[start+n]
+			s := step{rest.unknown, rest.segments + 1, start + n, item}
+			if !better(best[start], &s) {
+				best[start] = s
+			}
+		})
+	}
+
+	for start := 0; start < len(text); start = best[start].end {
+		if err := visitor(start, best[start].end, best[start].item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Internal helper methods -----------------------------------------------------
+
+// matchPrefixes calls fn for every non-empty key with an item set that is
+// a prefix of text, passing it the length of the key, shortest keys first.
+// It is VisitPrefixes without building the keys, text must be folded already.
+func (trie *Trie) matchPrefixes(text []byte, fn func(n int, item Item)) {
+	// Empty trie must be handled explicitly.
+	if trie.prefix == nil {
+		return
+	}
+
+	node := trie
+	offset := 0
+	for {
+		// Partial match means that there is no key going further.
+		if common := node.longestCommonPrefixLength(text[offset:]); common < len(node.prefix) {
+			return
+		}
+		offset += len(node.prefix)
+
+		if node.item != nil && offset != 0 {
+			fn(offset, node.item)
+		}
+
+		if offset == len(text) {
+			return
+		}
+		if node = node.children.next(text[offset]); node == nil {
+			return
+		}
+	}
+}
+
+// unknownLength returns the length of the unknown segment at the beginning
+// of text, which is a single rune or a single byte of invalid UTF-8.
+func unknownLength(text []byte) int {
+	_, n := utf8.DecodeRune(text)
+	return n
+}
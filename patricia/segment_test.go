@@ -0,0 +1,109 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// Tests -----------------------------------------------------------------------
+
+func TestTrie_Segment(t *testing.T) {
+	trie := trieOf(map[string]Item{"北京": 1, "北京大学": 2, "大学": 3, "生": 4, "": 5})
+
+	segments := segmentsOf(t, trie.Segment, "北京大学生x北")
+	expected := []string{"北京大学=2", "生=4", "x=<nil>", "北=<nil>"}
+	if !reflect.DeepEqual(segments, expected) {
+		t.Errorf("Unexpected segments, expected=%q, got=%q", expected, segments)
+	}
+}
+
+func TestTrie_SegmentInvalidUTF8(t *testing.T) {
+	trie := trieOf(map[string]Item{"ab": 1})
+
+	segments := segmentsOf(t, trie.Segment, "\xffab\xe4")
+	expected := []string{"\xff=<nil>", "ab=1", "\xe4=<nil>"}
+	if !reflect.DeepEqual(segments, expected) {
+		t.Errorf("Unexpected segments, expected=%q, got=%q", expected, segments)
+	}
+}
+
+func TestTrie_SegmentBestPath(t *testing.T) {
+	trie := trieOf(map[string]Item{"ab": 1, "abc": 2, "cd": 3, "d": 4, "e": 5})
+
+	// Greedy takes abc, leaving d on its own.
+	greedy := segmentsOf(t, trie.Segment, "abcde")
+	if expected := []string{"abc=2", "d=4", "e=5"}; !reflect.DeepEqual(greedy, expected) {
+		t.Errorf("Unexpected greedy segments, expected=%q, got=%q", expected, greedy)
+	}
+
+	// Both have three segments, longer segments at the beginning win.
+	best := segmentsOf(t, trie.SegmentBestPath, "abcde")
+	if expected := []string{"abc=2", "d=4", "e=5"}; !reflect.DeepEqual(best, expected) {
+		t.Errorf("Unexpected best path segments, expected=%q, got=%q", expected, best)
+	}
+
+	// Greedy leaves z unknown, the best path avoids that.
+	trie = trieOf(map[string]Item{"ab": 1, "abx": 2, "xyz": 3, "y": 4})
+	greedy = segmentsOf(t, trie.Segment, "abxyz")
+	if expected := []string{"abx=2", "y=4", "z=<nil>"}; !reflect.DeepEqual(greedy, expected) {
+		t.Errorf("Unexpected greedy segments, expected=%q, got=%q", expected, greedy)
+	}
+	best = segmentsOf(t, trie.SegmentBestPath, "abxyz")
+	if expected := []string{"ab=1", "xyz=3"}; !reflect.DeepEqual(best, expected) {
+		t.Errorf("Unexpected best path segments, expected=%q, got=%q", expected, best)
+	}
+}
+
+func TestTrie_SegmentReturnError(t *testing.T) {
+	trie := trieOf(map[string]Item{"a": 1})
+
+	someErr := errors.New("Something exploded")
+	for _, segment := range []func([]byte, SegmentFunc) error{trie.Segment, trie.SegmentBestPath} {
+		calls := 0
+		if err := segment([]byte("aaa"), func(start, end int, item Item) error {
+			calls++
+			return someErr
+		}); err != someErr {
+			t.Errorf("Unexpected error, expected=%v, got=%v", someErr, err)
+		}
+		if calls != 1 {
+			t.Errorf("Visitor called %v times after returning an error", calls)
+		}
+	}
+}
+
+func TestTrie_SegmentEmpty(t *testing.T) {
+	trie := NewTrie()
+	segments := segmentsOf(t, trie.SegmentBestPath, "ab")
+	if expected := []string{"a=<nil>", "b=<nil>"}; !reflect.DeepEqual(segments, expected) {
+		t.Errorf("Unexpected segments, expected=%q, got=%q", expected, segments)
+	}
+}
+
+// Helpers ---------------------------------------------------------------------
+
+func segmentsOf(t *testing.T, segment func([]byte, SegmentFunc) error, text string) []string {
+	var segments []string
+	prev := 0
+	if err := segment([]byte(text), func(start, end int, item Item) error {
+		if start != prev || end <= start {
+			t.Fatalf("Segments not adjacent: %v, %v-%v", prev, start, end)
+		}
+		prev = end
+		segments = append(segments, fmt.Sprintf("%s=%v", text[start:end], item))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if prev != len(text) {
+		t.Fatalf("Text not covered: %v/%v", prev, len(text))
+	}
+	return segments
+}
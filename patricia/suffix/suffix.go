@@ -0,0 +1,195 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+// Package suffix provides a substring index built on top of patricia.Trie.
+package suffix
+
+import (
+	"sort"
+
+	"github.com/tchap/go-patricia/v2/patricia"
+)
+
+//------------------------------------------------------------------------------
+// Index
+//------------------------------------------------------------------------------
+
+// Index stores all the suffixes of the strings added into a trie, each suffix
+// referencing back the strings it belongs to. A string contains a substring
+// exactly when one of its suffixes starts with the substring, so substring
+// search turns into a prefix search.
+//
+// The suffixes are never copied, the trie keys alias the single copy of every
+// string added. Still, the number of nodes grows with the total length of the
+// strings, so the index is meant for short strings like names or tags.
+//
+// Index is not thread-safe.
+type Index struct {
+	trie *patricia.Trie
+	len  int
+}
+
+// doc is a string added to the index.
+type doc struct {
+	key  patricia.Prefix
+	item patricia.Item
+}
+
+// postings is what is stored for every suffix shared by multiple strings,
+// the strings ending with it. A suffix of a single string, which is the common
+// case, stores the *doc directly to save an allocation.
+type postings []*doc
+
+func postingsOf(item patricia.Item) postings {
+	switch item := item.(type) {
+	case *doc:
+		return postings{item}
+	case postings:
+		return item
+	}
+	return nil
+}
+
+// Public API ------------------------------------------------------------------
+
+// New creates an empty index, the options are passed to patricia.NewTrie.
+func New(options ...patricia.Option) *Index {
+	options = append(options[:len(options):len(options)], patricia.CopyKeys(false))
+	return &Index{
+		trie: patricia.NewTrie(options...),
+	}
+}
+
+// Add adds key into the index, associating it with item.
+// In case key is present already, only the item is replaced.
+func (index *Index) Add(key patricia.Prefix, item patricia.Item) {
+	// Nil prefix not allowed.
+	if key == nil {
+		panic(patricia.ErrNilPrefix)
+	}
+
+	if d := index.lookup(key); d != nil {
+		d.item = item
+		return
+	}
+
+	d := &doc{
+		key:  append(make(patricia.Prefix, 0, len(key)), key...),
+		item: item,
+	}
+	index.forEachSuffix(d.key, func(suffix patricia.Prefix) {
+		index.trie.Update(suffix, func(old patricia.Item, exists bool) (patricia.Item, bool) {
+			if !exists {
+				return d, true
+			}
+			return append(postingsOf(old), d), true
+		})
+	})
+	index.len++
+}
+
+// Remove removes key from the index.
+//
+// True is returned if key was present.
+func (index *Index) Remove(key patricia.Prefix) (removed bool) {
+	d := index.lookup(key)
+	if d == nil {
+		return false
+	}
+
+	index.forEachSuffix(d.key, func(suffix patricia.Prefix) {
+		index.trie.Update(suffix, func(old patricia.Item, exists bool) (patricia.Item, bool) {
+			docs := postingsOf(old)
+			for i := range docs {
+				if docs[i] == d {
+					// Never modify the slice in place, it may be shared.
+					docs = append(docs[:i:i], docs[i+1:]...)
+					break
+				}
+			}
+			if len(docs) == 1 {
+				return docs[0], true
+			}
+			return docs, len(docs) != 0
+		})
+	})
+	index.len--
+	return true
+}
+
+// Get returns the item associated with key, nil when missing.
+func (index *Index) Get(key patricia.Prefix) patricia.Item {
+	if d := index.lookup(key); d != nil {
+		return d.item
+	}
+	return nil
+}
+
+// Len returns the number of strings in the index.
+func (index *Index) Len() int {
+	return index.len
+}
+
+// FindContaining returns all the strings containing substr,
+// in alphabetical order. The keys returned must not be modified.
+func (index *Index) FindContaining(substr patricia.Prefix) []patricia.KeyItem {
+	seen := make(map[*doc]bool)
+	var docs []*doc
+	index.trie.VisitSubtree(substr, func(suffix patricia.Prefix, item patricia.Item) error {
+		for _, d := range postingsOf(item) {
+			if !seen[d] {
+				seen[d] = true
+				docs = append(docs, d)
+			}
+		}
+		return nil
+	})
+	return sortedKeyItems(docs)
+}
+
+// FindSuffix returns all the strings ending with suffix,
+// in alphabetical order. The keys returned must not be modified.
+func (index *Index) FindSuffix(suffix patricia.Prefix) []patricia.KeyItem {
+	return sortedKeyItems(postingsOf(index.trie.Get(suffix)))
+}
+
+// Internal helper methods -----------------------------------------------------
+
+// lookup returns the doc for key, which is one of the docs with suffix key.
+func (index *Index) lookup(key patricia.Prefix) *doc {
+	for _, d := range postingsOf(index.trie.Get(key)) {
+		if len(d.key) == len(key) {
+			return d
+		}
+	}
+	return nil
+}
+
+// forEachSuffix calls fn for all non-empty suffixes of key, longest first.
+// The empty suffix is only used for the empty string itself.
+func (index *Index) forEachSuffix(key patricia.Prefix, fn func(suffix patricia.Prefix)) {
+	if len(key) == 0 {
+		fn(key)
+		return
+	}
+	for i := range key {
+		fn(key[i:])
+	}
+}
+
+func sortedKeyItems(docs []*doc) []patricia.KeyItem {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	result := make([]patricia.KeyItem, len(docs))
+	for i, d := range docs {
+		result[i] = patricia.KeyItem{Key: d.key, Item: d.item}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return string(result[i].Key) < string(result[j].Key)
+	})
+	return result
+}
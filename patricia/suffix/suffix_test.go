@@ -0,0 +1,219 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package suffix
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+
+	"github.com/tchap/go-patricia/v2/patricia"
+)
+
+// Tests -----------------------------------------------------------------------
+
+func TestIndex_FindContaining(t *testing.T) {
+	index := New()
+	for i, key := range []string{"Pepa", "Pepa Zdepa", "Honza", "Jenik", "Zdepa"} {
+		index.Add(patricia.Prefix(key), i)
+	}
+
+	cases := []struct {
+		substr   string
+		expected []string
+	}{
+		{"epa", []string{"Pepa", "Pepa Zdepa", "Zdepa"}},
+		{"a Z", []string{"Pepa Zdepa"}},
+		{"en", []string{"Jenik"}},
+		{"Honza", []string{"Honza"}},
+		{"x", nil},
+	}
+	for _, c := range cases {
+		if keys := keysOf(index.FindContaining(patricia.Prefix(c.substr))); !reflect.DeepEqual(keys, c.expected) {
+			t.Errorf("Unexpected result, substr=%q, expected=%q, got=%q", c.substr, c.expected, keys)
+		}
+	}
+}
+
+func TestIndex_FindSuffix(t *testing.T) {
+	index := New()
+	for i, key := range []string{"Pepa", "Pepa Zdepa", "Honza", "Zdepa"} {
+		index.Add(patricia.Prefix(key), i)
+	}
+
+	expected := []string{"Pepa Zdepa", "Zdepa"}
+	if keys := keysOf(index.FindSuffix(patricia.Prefix("Zdepa"))); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected result, expected=%q, got=%q", expected, keys)
+	}
+
+	// A suffix, not a substring.
+	if keys := keysOf(index.FindSuffix(patricia.Prefix("Zdep"))); len(keys) != 0 {
+		t.Errorf("Unexpected result: %q", keys)
+	}
+}
+
+func TestIndex_AddRemove(t *testing.T) {
+	index := New()
+
+	index.Add(patricia.Prefix("abab"), 1)
+	index.Add(patricia.Prefix("bab"), 2)
+	index.Add(patricia.Prefix("abab"), 3)
+	index.Add(patricia.Prefix(""), 4)
+
+	if n := index.Len(); n != 3 {
+		t.Errorf("Unexpected length, expected=3, got=%v", n)
+	}
+	if item := index.Get(patricia.Prefix("abab")); item != 3 {
+		t.Errorf("Unexpected item, expected=3, got=%v", item)
+	}
+	if item := index.Get(patricia.Prefix("ab")); item != nil {
+		t.Errorf("Unexpected item for a suffix: %v", item)
+	}
+
+	// Repeated occurrences of the substring are reported once.
+	expected := []patricia.KeyItem{
+		{Key: patricia.Prefix("abab"), Item: 3},
+		{Key: patricia.Prefix("bab"), Item: 2},
+	}
+	if result := index.FindContaining(patricia.Prefix("ab")); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Unexpected result, expected=%v, got=%v", expected, result)
+	}
+
+	if !index.Remove(patricia.Prefix("abab")) {
+		t.Error("Remove failed")
+	}
+	if index.Remove(patricia.Prefix("abab")) {
+		t.Error("Extra remove succeeded")
+	}
+	if keys := keysOf(index.FindContaining(patricia.Prefix("ab"))); !reflect.DeepEqual(keys, []string{"bab"}) {
+		t.Errorf("Unexpected result after remove: %q", keys)
+	}
+
+	index.Remove(patricia.Prefix("bab"))
+	index.Remove(patricia.Prefix(""))
+	if keys := keysOf(index.FindContaining(patricia.Prefix{})); len(keys) != 0 {
+		t.Errorf("Unexpected keys left: %q", keys)
+	}
+}
+
+func TestIndex_Random(t *testing.T) {
+	keys := randomKeys(rand.New(rand.NewSource(42)), 500, "abc")
+
+	index := New(patricia.MaxPrefixPerNode(3))
+	for i, key := range keys {
+		index.Add(patricia.Prefix(key), i)
+	}
+	for _, key := range keys[:100] {
+		index.Remove(patricia.Prefix(key))
+	}
+	left := keys[100:]
+
+	for _, substr := range []string{"a", "ab", "cba", "bbb", "abcab"} {
+		var expected []string
+		for _, key := range left {
+			if bytes.Contains([]byte(key), []byte(substr)) {
+				expected = append(expected, key)
+			}
+		}
+		sort.Strings(expected)
+
+		if got := keysOf(index.FindContaining(patricia.Prefix(substr))); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Unexpected result, substr=%q, expected=%q, got=%q", substr, expected, got)
+		}
+	}
+}
+
+// Benchmarks ------------------------------------------------------------------
+
+// BenchmarkIndex_Memory reports the heap occupied per string added.
+func BenchmarkIndex_Memory(b *testing.B) {
+	keys := randomKeys(rand.New(rand.NewSource(42)), 10000, "abcdefghijklmnopqrstuvwxyz")
+
+	for _, bench := range []struct {
+		name  string
+		build func() interface{}
+	}{
+		{"Index", func() interface{} {
+			index := New()
+			for i, key := range keys {
+				index.Add(patricia.Prefix(key), i)
+			}
+			return index
+		}},
+		// The naive approach, copying every suffix into a trie.
+		{"CopiedSuffixes", func() interface{} {
+			trie := patricia.NewTrie()
+			for i, key := range keys {
+				for j := range key {
+					trie.Set(patricia.Prefix(key[j:]), i)
+				}
+			}
+			return trie
+		}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			var heap uint64
+			for i := 0; i < b.N; i++ {
+				before := heapAlloc()
+				index := bench.build()
+				heap += heapAlloc() - before
+				runtime.KeepAlive(index)
+			}
+			b.ReportMetric(float64(heap)/float64(b.N)/float64(len(keys)), "heap-B/string")
+		})
+	}
+}
+
+func BenchmarkIndex_FindContaining(b *testing.B) {
+	keys := randomKeys(rand.New(rand.NewSource(42)), 10000, "abcdefghijklmnopqrstuvwxyz")
+
+	index := New()
+	for i, key := range keys {
+		index.Add(patricia.Prefix(key), i)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		index.FindContaining(patricia.Prefix("ab"))
+	}
+}
+
+// Helpers ---------------------------------------------------------------------
+
+func keysOf(result []patricia.KeyItem) []string {
+	var keys []string
+	for _, ki := range result {
+		keys = append(keys, string(ki.Key))
+	}
+	return keys
+}
+
+// randomKeys returns n distinct random keys.
+func randomKeys(rnd *rand.Rand, n int, alphabet string) []string {
+	seen := make(map[string]bool)
+	keys := make([]string, 0, n)
+	for len(keys) != n {
+		key := make([]byte, 1+rnd.Intn(12))
+		for j := range key {
+			key[j] = alphabet[rnd.Intn(len(alphabet))]
+		}
+		if !seen[string(key)] {
+			seen[string(key)] = true
+			keys = append(keys, string(key))
+		}
+	}
+	return keys
+}
+
+func heapAlloc() uint64 {
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
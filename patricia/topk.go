@@ -0,0 +1,170 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+import (
+	"container/heap"
+	"math"
+)
+
+//------------------------------------------------------------------------------
+// Top-K
+//------------------------------------------------------------------------------
+
+// MaxScore is an Aggregator keeping the maximum score of the items in every
+// subtree. Tries created using Aggregation(MaxScore(score)) let TopK skip
+// the subtrees that cannot make it into the result.
+type MaxScore func(item Item) float64
+
+func (score MaxScore) Summarize(item Item) Summary {
+	return score(item)
+}
+
+func (MaxScore) Combine(a, b Summary) Summary {
+	return math.Max(a.(float64), b.(float64))
+}
+
+// ScoredItem is a single result returned by TopK.
+type ScoredItem struct {
+	Key   Prefix
+	Item  Item
+	Score float64
+}
+
+// TopK returns up to k items with keys starting with prefix, having the highest
+// scores. The items are returned in the order of decreasing score, items with
+// the same score in key order.
+//
+// The branches are explored best-first. When the trie is created using
+// Aggregation(MaxScore(fn)), the items are scored using fn, so that the subtree
+// summaries are exact bounds and only the branches that may contain a result
+// are visited. score must be nil then, otherwise TopK panics with
+// ErrScoreAggregated. Without the aggregator, the items are scored using score
+// and the whole subtree is visited.
+func (trie *Trie) TopK(prefix Prefix, k int, score func(item Item) float64) []ScoredItem {
+	// Nil prefix not allowed.
+	if prefix == nil {
+		panic(ErrNilPrefix)
+	}
+
+	// The bounds must come from the same function as the scores.
+	if maxScore, ok := trie.aggregator.(MaxScore); ok {
+		if score != nil {
+			panic(ErrScoreAggregated)
+		}
+		score = maxScore
+	} else if score == nil {
+		panic(ErrNilScore)
+	}
+
+	// Empty trie must be handled explicitly.
+	if trie.prefix == nil || k <= 0 {
+		return nil
+	}
+
+	prefix = trie.encodeKey(prefix)
+	_, root, found, leftover := trie.findSubtree(prefix)
+	if !found {
+		return nil
+	}
+
+	key := append(append(make(Prefix, 0, len(prefix)+len(leftover)), prefix...), leftover...)
+	queue := &topKQueue{trie: trie}
+	queue.pushNode(root, key)
+
+	var result []ScoredItem
+	for queue.Len() != 0 && len(result) < k {
+		entry := heap.Pop(queue).(topKEntry)
+		if entry.node == nil {
+			result = append(result, ScoredItem{
+				Key:   trie.decodeKey(nil, entry.key),
+				Item:  entry.item,
+				Score: entry.score,
+			})
+			continue
+		}
+
+		node := entry.node
+		if node.item != nil {
+			heap.Push(queue, topKEntry{
+				key:   node.visitKey(entry.key),
+				item:  node.item,
+				score: score(node.item),
+			})
+		}
+		for _, child := range node.children.sorted() {
+			childKey := append(entry.key[:len(entry.key):len(entry.key)], child.prefix...)
+			queue.pushNode(child, childKey)
+		}
+	}
+	return result
+}
+
+// Internal helper methods -----------------------------------------------------
+
+// topKEntry is either a subtree to be explored, its score being the upper bound
+// for the scores of its items, or an item ready to be returned.
+type topKEntry struct {
+	node  *Trie
+	key   Prefix
+	item  Item
+	score float64
+}
+
+// topKQueue is a max-heap of entries. Subtrees go before items with the same
+// score so that items with equal scores are all queued before returning any.
+type topKQueue struct {
+	entries []topKEntry
+	// trie defines the key order.
+	trie *Trie
+}
+
+func (q *topKQueue) Len() int {
+	return len(q.entries)
+}
+
+func (q *topKQueue) Less(i, j int) bool {
+	a, b := &q.entries[i], &q.entries[j]
+	switch {
+	case a.score != b.score:
+		return a.score > b.score
+	case (a.node == nil) != (b.node == nil):
+		return a.node != nil
+	}
+	return lessKey(q.trie.keyOrder, a.key, b.key)
+}
+
+func (q *topKQueue) Swap(i, j int) {
+	q.entries[i], q.entries[j] = q.entries[j], q.entries[i]
+}
+
+func (q *topKQueue) Push(x interface{}) {
+	q.entries = append(q.entries, x.(topKEntry))
+}
+
+func (q *topKQueue) Pop() interface{} {
+	old := q.entries
+	entry := old[len(old)-1]
+	old[len(old)-1] = topKEntry{}
+	q.entries = old[:len(old)-1]
+	return entry
+}
+
+// pushNode queues node unless it is known to be empty.
+func (q *topKQueue) pushNode(node *Trie, key Prefix) {
+	bound := math.Inf(1)
+	if _, ok := node.aggregator.(MaxScore); ok {
+		if node.summary == nil {
+			return
+		}
+		bound = node.summary.(float64)
+	}
+	heap.Push(q, topKEntry{
+		node:  node,
+		key:   key,
+		score: bound,
+	})
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package patricia
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Tests -----------------------------------------------------------------------
+
+func TestTrie_TopK(t *testing.T) {
+	score := func(item Item) float64 {
+		return float64(item.(int))
+	}
+
+	for _, tc := range []struct {
+		options []Option
+		score   func(item Item) float64
+	}{
+		{nil, score},
+		{[]Option{Aggregation(MaxScore(score))}, nil},
+	} {
+		trie := NewTrie(tc.options...)
+
+		data := []testData{
+			{"Pepa", 5, success},
+			{"Pepa Zdepa", 20, success},
+			{"Pepa Kuchar", 10, success},
+			{"Pepicek", 10, success},
+			{"Honza", 30, success},
+		}
+
+		for _, v := range data {
+			t.Logf("INSERT prefix=%v, item=%v, success=%v", v.key, v.value, v.retVal)
+			if ok := trie.Insert([]byte(v.key), v.value); ok != v.retVal {
+				t.Fatalf("Unexpected return value, expected=%v, got=%v", v.retVal, ok)
+			}
+		}
+
+		expected := []ScoredItem{
+			{Prefix("Pepa Zdepa"), 20, 20},
+			{Prefix("Pepa Kuchar"), 10, 10},
+			{Prefix("Pepicek"), 10, 10},
+		}
+		if top := trie.TopK(Prefix("Pep"), 3, tc.score); !reflect.DeepEqual(top, expected) {
+			t.Errorf("Unexpected result, expected=%v, got=%v", expected, top)
+		}
+
+		if top := trie.TopK(Prefix("Jenik"), 3, tc.score); len(top) != 0 {
+			t.Errorf("Unexpected result: %v", top)
+		}
+		if top := trie.TopK(Prefix{}, 10, tc.score); len(top) != len(data) {
+			t.Errorf("Unexpected number of results, expected=%v, got=%v", len(data), len(top))
+		}
+	}
+}
+
+func TestTrie_TopKRandom(t *testing.T) {
+	score := func(item Item) float64 {
+		return float64(item.(int))
+	}
+
+	items := make(map[string]Item)
+	for i := 0; i < 2000; i++ {
+		key := make([]byte, 1+rand.Intn(8))
+		for j := range key {
+			key[j] = "abc"[rand.Intn(3)]
+		}
+		items[string(key)] = rand.Intn(50)
+	}
+
+	for _, tc := range []struct {
+		options []Option
+		score   func(item Item) float64
+	}{
+		{nil, score},
+		{[]Option{Aggregation(MaxScore(score)), MaxPrefixPerNode(2)}, nil},
+	} {
+		trie := trieOf(items, tc.options...)
+
+		for _, prefix := range []string{"", "a", "bc", "cab"} {
+			var expected []ScoredItem
+			for key, item := range items {
+				if strings.HasPrefix(key, prefix) {
+					expected = append(expected, ScoredItem{Prefix(key), item, score(item)})
+				}
+			}
+			sort.Slice(expected, func(i, j int) bool {
+				if expected[i].Score != expected[j].Score {
+					return expected[i].Score > expected[j].Score
+				}
+				return string(expected[i].Key) < string(expected[j].Key)
+			})
+			if len(expected) > 10 {
+				expected = expected[:10]
+			}
+
+			if top := trie.TopK(Prefix(prefix), 10, tc.score); !reflect.DeepEqual(top, expected) {
+				t.Errorf("Unexpected result, prefix=%q, expected=%v, got=%v", prefix, expected, top)
+			}
+		}
+	}
+}
+
+func TestTrie_TopKScoreFromAggregator(t *testing.T) {
+	score := func(item Item) float64 {
+		return float64(item.(int))
+	}
+	trie := trieOf(map[string]Item{"a": 1, "b": 3, "c": 2}, Aggregation(MaxScore(score)))
+
+	expected := []ScoredItem{{Prefix("b"), 3, 3}, {Prefix("c"), 2, 2}}
+	if top := trie.TopK(Prefix{}, 2, nil); !reflect.DeepEqual(top, expected) {
+		t.Errorf("Unexpected result, expected=%v, got=%v", expected, top)
+	}
+
+	// A different score function would break the bounds, so it is rejected.
+	defer func() {
+		if r := recover(); r != ErrScoreAggregated {
+			t.Errorf("Unexpected panic, expected=%v, got=%v", ErrScoreAggregated, r)
+		}
+	}()
+	trie.TopK(Prefix{}, 2, score)
+}
+
+// Benchmarks ------------------------------------------------------------------
+
+func BenchmarkTopK(b *testing.B) {
+	score := func(item Item) float64 {
+		return float64(item.(int))
+	}
+
+	for _, bench := range []struct {
+		name    string
+		options []Option
+		score   func(item Item) float64
+	}{
+		{"plain", nil, score},
+		{"annotated", []Option{Aggregation(MaxScore(score))}, nil},
+	} {
+		trie := NewTrie(bench.options...)
+		for i := 0; i < 100000; i++ {
+			key := make([]byte, 1+rand.Intn(10))
+			for j := range key {
+				key[j] = byte('a' + rand.Intn(26))
+			}
+			trie.Set(key, rand.Intn(1000000))
+		}
+
+		b.Run(bench.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				trie.TopK(Prefix("a"), 10, bench.score)
+			}
+		})
+	}
+}
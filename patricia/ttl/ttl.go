@@ -0,0 +1,250 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+// Package ttl provides a patricia trie with items that expire.
+package ttl
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tchap/go-patricia/v2/patricia"
+)
+
+//------------------------------------------------------------------------------
+// Trie
+//------------------------------------------------------------------------------
+
+// Trie wraps patricia.Trie so that items can be given a time to live.
+//
+// Expired items are hidden from all the methods right away, but they keep
+// occupying memory until Sweep is called, either explicitly or by the janitor
+// started using StartJanitor.
+//
+// Unlike patricia.Trie, Trie is safe for concurrent use. Visitors are called
+// with the trie locked, so they must not call any method of the trie.
+type Trie struct {
+	mu   sync.Mutex
+	trie *patricia.Trie
+	now  func() time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// entry is what is actually stored in the underlying trie.
+type entry struct {
+	item patricia.Item
+	// expires is the zero time for items that never expire.
+	expires time.Time
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// expiry is the summary of a subtree, telling Sweep which subtrees
+// to skip and which ones to drop as a whole without visiting the items.
+type expiry struct {
+	// earliest and latest are the zero time unless some item expires.
+	earliest time.Time
+	latest   time.Time
+	// forever is set when some item never expires.
+	forever bool
+}
+
+// expiredAt returns whether any item in the subtree is expired at now.
+func (e expiry) expiredAt(now time.Time) bool {
+	return !e.earliest.IsZero() && !now.Before(e.earliest)
+}
+
+// allExpiredAt returns whether all items in the subtree are expired at now.
+func (e expiry) allExpiredAt(now time.Time) bool {
+	return !e.forever && e.expiredAt(now) && !now.Before(e.latest)
+}
+
+type expiryAggregator struct{}
+
+func (expiryAggregator) Summarize(item patricia.Item) patricia.Summary {
+	expires := item.(*entry).expires
+	return expiry{
+		earliest: expires,
+		latest:   expires,
+		forever:  expires.IsZero(),
+	}
+}
+
+func (expiryAggregator) Combine(a, b patricia.Summary) patricia.Summary {
+	x, y := a.(expiry), b.(expiry)
+	if x.earliest.IsZero() || !y.earliest.IsZero() && y.earliest.Before(x.earliest) {
+		x.earliest = y.earliest
+	}
+	if y.latest.After(x.latest) {
+		x.latest = y.latest
+	}
+	x.forever = x.forever || y.forever
+	return x
+}
+
+// Public API ------------------------------------------------------------------
+
+// New creates a new trie, the options are passed to patricia.NewTrie.
+func New(options ...patricia.Option) *Trie {
+	options = append(options[:len(options):len(options)], patricia.Aggregation(expiryAggregator{}))
+	return &Trie{
+		trie: patricia.NewTrie(options...),
+		now:  time.Now,
+	}
+}
+
+// Set sets the item for key, the item never expires.
+func (trie *Trie) Set(key patricia.Prefix, item patricia.Item) {
+	trie.set(key, item, time.Time{})
+}
+
+// SetWithTTL sets the item for key, the item expires once ttl elapses.
+func (trie *Trie) SetWithTTL(key patricia.Prefix, item patricia.Item, ttl time.Duration) {
+	trie.mu.Lock()
+	expires := trie.now().Add(ttl)
+	trie.mu.Unlock()
+
+	trie.set(key, item, expires)
+}
+
+// Get returns the item located at key, nil when missing or expired.
+func (trie *Trie) Get(key patricia.Prefix) patricia.Item {
+	trie.mu.Lock()
+	defer trie.mu.Unlock()
+
+	if e, ok := trie.trie.Get(key).(*entry); ok && !e.expired(trie.now()) {
+		return e.item
+	}
+	return nil
+}
+
+// Delete deletes the item located at key.
+//
+// True is returned if an item that had not expired yet was deleted.
+func (trie *Trie) Delete(key patricia.Prefix) (deleted bool) {
+	trie.mu.Lock()
+	defer trie.mu.Unlock()
+
+	item, ok := trie.trie.Remove(key)
+	return ok && !item.(*entry).expired(trie.now())
+}
+
+// Visit works like patricia.Trie.Visit, skipping expired items.
+func (trie *Trie) Visit(visitor patricia.VisitorFunc) error {
+	trie.mu.Lock()
+	defer trie.mu.Unlock()
+
+	return trie.trie.Visit(trie.unwrap(visitor))
+}
+
+// VisitSubtree works like patricia.Trie.VisitSubtree, skipping expired items.
+func (trie *Trie) VisitSubtree(prefix patricia.Prefix, visitor patricia.VisitorFunc) error {
+	trie.mu.Lock()
+	defer trie.mu.Unlock()
+
+	return trie.trie.VisitSubtree(prefix, trie.unwrap(visitor))
+}
+
+// VisitPrefixes works like patricia.Trie.VisitPrefixes, skipping expired items.
+func (trie *Trie) VisitPrefixes(key patricia.Prefix, visitor patricia.VisitorFunc) error {
+	trie.mu.Lock()
+	defer trie.mu.Unlock()
+
+	return trie.trie.VisitPrefixes(key, trie.unwrap(visitor))
+}
+
+// Sweep deletes all items that are expired at now, in a single pass.
+// Subtrees with no expired items are skipped and subtrees with all the items
+// expired are dropped as a whole, so only the items in the subtrees mixing
+// both are visited. The number of items deleted is returned.
+func (trie *Trie) Sweep(now time.Time) (removed int) {
+	trie.mu.Lock()
+	defer trie.mu.Unlock()
+
+	removed, _ = trie.trie.PruneSubtrees(func(summary patricia.Summary) (visit, removeAll bool) {
+		e, ok := summary.(expiry)
+		if !ok {
+			return false, false
+		}
+		return e.expiredAt(now), e.allExpiredAt(now)
+	}, func(prefix patricia.Prefix, item patricia.Item) (bool, error) {
+		return item.(*entry).expired(now), nil
+	})
+	return
+}
+
+// StartJanitor starts a goroutine calling Sweep every interval,
+// stopping the one started previously, if any.
+func (trie *Trie) StartJanitor(interval time.Duration) {
+	trie.StopJanitor()
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				trie.mu.Lock()
+				now := trie.now()
+				trie.mu.Unlock()
+
+				trie.Sweep(now)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	trie.mu.Lock()
+	trie.stop, trie.done = stop, done
+	trie.mu.Unlock()
+}
+
+// StopJanitor stops the goroutine started by StartJanitor and waits for it
+// to return. It does nothing when there is no janitor running.
+func (trie *Trie) StopJanitor() {
+	trie.mu.Lock()
+	stop, done := trie.stop, trie.done
+	trie.stop, trie.done = nil, nil
+	trie.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// Internal helper methods -----------------------------------------------------
+
+func (trie *Trie) set(key patricia.Prefix, item patricia.Item, expires time.Time) {
+	trie.mu.Lock()
+	defer trie.mu.Unlock()
+
+	// Storing nil is the same as deleting, as usual.
+	if item == nil {
+		trie.trie.Delete(key)
+		return
+	}
+	trie.trie.Set(key, &entry{item, expires})
+}
+
+// unwrap turns visitor into a visitor of entries, skipping the expired ones.
+func (trie *Trie) unwrap(visitor patricia.VisitorFunc) patricia.VisitorFunc {
+	now := trie.now()
+	return func(prefix patricia.Prefix, item patricia.Item) error {
+		if e := item.(*entry); !e.expired(now) {
+			return visitor(prefix, e.item)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright (c) 2014 The go-patricia AUTHORS
+//
+// Use of this source code is governed by The MIT License
+// that can be found in the LICENSE file.
+
+package ttl
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tchap/go-patricia/v2/patricia"
+)
+
+// Tests -----------------------------------------------------------------------
+
+func TestTrie_SetWithTTL(t *testing.T) {
+	trie, clock := newTestTrie()
+
+	trie.Set(patricia.Prefix("Pepa"), 1)
+	trie.SetWithTTL(patricia.Prefix("Pepa Zdepa"), 2, time.Minute)
+	trie.SetWithTTL(patricia.Prefix("Honza"), 3, time.Hour)
+
+	if item := trie.Get(patricia.Prefix("Pepa Zdepa")); item != 2 {
+		t.Errorf("Unexpected item, expected=2, got=%v", item)
+	}
+
+	*clock = clock.Add(time.Minute)
+
+	if item := trie.Get(patricia.Prefix("Pepa Zdepa")); item != nil {
+		t.Errorf("Unexpected item, expected=<nil>, got=%v", item)
+	}
+	if item := trie.Get(patricia.Prefix("Honza")); item != 3 {
+		t.Errorf("Unexpected item, expected=3, got=%v", item)
+	}
+	if item := trie.Get(patricia.Prefix("Pepa")); item != 1 {
+		t.Errorf("Unexpected item, expected=1, got=%v", item)
+	}
+
+	*clock = clock.Add(time.Hour)
+
+	if item := trie.Get(patricia.Prefix("Honza")); item != nil {
+		t.Errorf("Unexpected item, expected=<nil>, got=%v", item)
+	}
+	if item := trie.Get(patricia.Prefix("Pepa")); item != 1 {
+		t.Errorf("Unexpected item, expected=1, got=%v", item)
+	}
+}
+
+func TestTrie_VisitHidesExpired(t *testing.T) {
+	trie, clock := newTestTrie()
+
+	trie.SetWithTTL(patricia.Prefix("Pepa"), 1, time.Minute)
+	trie.SetWithTTL(patricia.Prefix("Pepa Zdepa"), 2, time.Hour)
+	trie.SetWithTTL(patricia.Prefix("Pepa Kuchar"), 3, time.Minute)
+	trie.Set(patricia.Prefix("Honza"), 4)
+
+	*clock = clock.Add(time.Minute)
+
+	var visited []string
+	collect := func(prefix patricia.Prefix, item patricia.Item) error {
+		visited = append(visited, string(prefix))
+		return nil
+	}
+
+	trie.Visit(collect)
+	if expected := []string{"Honza", "Pepa Zdepa"}; !reflect.DeepEqual(visited, expected) {
+		t.Errorf("Unexpected keys visited, expected=%q, got=%q", expected, visited)
+	}
+
+	visited = nil
+	trie.VisitSubtree(patricia.Prefix("Pepa"), collect)
+	if expected := []string{"Pepa Zdepa"}; !reflect.DeepEqual(visited, expected) {
+		t.Errorf("Unexpected keys visited, expected=%q, got=%q", expected, visited)
+	}
+
+	visited = nil
+	trie.VisitPrefixes(patricia.Prefix("Pepa Zdepa"), collect)
+	if expected := []string{"Pepa Zdepa"}; !reflect.DeepEqual(visited, expected) {
+		t.Errorf("Unexpected keys visited, expected=%q, got=%q", expected, visited)
+	}
+}
+
+func TestTrie_Delete(t *testing.T) {
+	trie, clock := newTestTrie()
+
+	trie.SetWithTTL(patricia.Prefix("Pepa"), 1, time.Minute)
+	trie.SetWithTTL(patricia.Prefix("Honza"), 2, time.Hour)
+
+	*clock = clock.Add(time.Minute)
+
+	if ok := trie.Delete(patricia.Prefix("Pepa")); ok {
+		t.Error("Expired item reported as deleted")
+	}
+	if ok := trie.Delete(patricia.Prefix("Honza")); !ok {
+		t.Error("Live item not reported as deleted")
+	}
+}
+
+func TestTrie_Sweep(t *testing.T) {
+	trie, clock := newTestTrie()
+
+	trie.SetWithTTL(patricia.Prefix("Pepa"), 1, time.Minute)
+	trie.SetWithTTL(patricia.Prefix("Pepa Zdepa"), 2, time.Hour)
+	trie.SetWithTTL(patricia.Prefix("Pepa Kuchar"), 3, time.Minute)
+	trie.Set(patricia.Prefix("Honza"), 4)
+
+	if removed := trie.Sweep(*clock); removed != 0 {
+		t.Errorf("Unexpected number of items swept, expected=0, got=%v", removed)
+	}
+	if removed := trie.Sweep(clock.Add(time.Minute)); removed != 2 {
+		t.Errorf("Unexpected number of items swept, expected=2, got=%v", removed)
+	}
+	if removed := trie.Sweep(clock.Add(24 * time.Hour)); removed != 1 {
+		t.Errorf("Unexpected number of items swept, expected=1, got=%v", removed)
+	}
+
+	// Only the item that never expires is left in the underlying trie.
+	var keys []string
+	trie.trie.Visit(func(prefix patricia.Prefix, item patricia.Item) error {
+		keys = append(keys, string(prefix))
+		return nil
+	})
+	if expected := []string{"Honza"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected keys left, expected=%q, got=%q", expected, keys)
+	}
+
+	// No item left can expire, there is nothing to sweep.
+	if summary := trie.trie.AggregateSubtree(patricia.Prefix{}).(expiry); summary.expiredAt(clock.Add(1000 * time.Hour)) {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+}
+
+func TestTrie_SweepSubtrees(t *testing.T) {
+	trie, clock := newTestTrie()
+
+	for i, key := range []string{"tmp/a", "tmp/b", "tmp/c/d", "tmp"} {
+		trie.SetWithTTL(patricia.Prefix(key), i, time.Minute)
+	}
+	trie.SetWithTTL(patricia.Prefix("mixed/a"), 4, time.Minute)
+	trie.Set(patricia.Prefix("mixed/b"), 5)
+	trie.SetWithTTL(patricia.Prefix("later/a"), 6, time.Hour)
+
+	if removed := trie.Sweep(clock.Add(time.Minute)); removed != 5 {
+		t.Errorf("Unexpected number of items swept, expected=5, got=%v", removed)
+	}
+
+	var keys []string
+	trie.trie.Visit(func(prefix patricia.Prefix, item patricia.Item) error {
+		keys = append(keys, string(prefix))
+		return nil
+	})
+	if expected := []string{"later/a", "mixed/b"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected keys left, expected=%q, got=%q", expected, keys)
+	}
+
+	summary := trie.trie.AggregateSubtree(patricia.Prefix{}).(expiry)
+	if !summary.forever || !summary.earliest.Equal(clock.Add(time.Hour)) {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+}
+
+func TestTrie_Janitor(t *testing.T) {
+	trie := New()
+
+	trie.SetWithTTL(patricia.Prefix("Pepa"), 1, time.Millisecond)
+	trie.Set(patricia.Prefix("Honza"), 2)
+
+	trie.StartJanitor(time.Millisecond)
+	defer trie.StopJanitor()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		trie.mu.Lock()
+		swept := trie.trie.Get(patricia.Prefix("Pepa")) == nil
+		trie.mu.Unlock()
+
+		if swept {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expired item not swept by the janitor")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if item := trie.Get(patricia.Prefix("Honza")); item != 2 {
+		t.Errorf("Unexpected item, expected=2, got=%v", item)
+	}
+}
+
+// Helpers ---------------------------------------------------------------------
+
+func newTestTrie() (*Trie, *time.Time) {
+	clock := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+	trie := New()
+	trie.now = func() time.Time {
+		return clock
+	}
+	return trie, &clock
+}